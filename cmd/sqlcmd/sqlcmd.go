@@ -8,17 +8,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net"
 	"os"
 	"regexp"
 	"runtime/trace"
+	"slices"
 	"strconv"
 	"strings"
-	"time"
 
 	mssql "github.com/microsoft/go-mssqldb"
 	"github.com/microsoft/go-mssqldb/azuread"
-	"github.com/microsoft/go-mssqldb/msdsn"
+	"github.com/microsoft/go-sqlcmd/internal/browser"
 	"github.com/microsoft/go-sqlcmd/internal/localizer"
 	"github.com/microsoft/go-sqlcmd/pkg/console"
 	"github.com/microsoft/go-sqlcmd/pkg/sqlcmd"
@@ -66,6 +65,7 @@ type SQLCmdArguments struct {
 	ErrorsToStderr              *int
 	Headers                     int
 	UnicodeOutputFile           bool
+	CodePage                    string
 	Version                     bool
 	ColumnSeparator             string
 	ScreenWidth                 *int
@@ -84,6 +84,9 @@ type SQLCmdArguments struct {
 	TraceFile                   string
 	ServerNameOverride          string
 	RawErrors                   bool
+	Format                      string
+	PerfStats                   *int
+	ErrorsFormat                string
 	// Keep Help at the end of the list
 	Help  bool
 	Ascii bool
@@ -105,6 +108,16 @@ func (args *SQLCmdArguments) runStartupScript() bool {
 	return args.DisableCmd == nil
 }
 
+func (args *SQLCmdArguments) getPerfStatsFormat() sqlcmd.PerfStatsFormat {
+	switch {
+	case args.PerfStats == nil:
+		return sqlcmd.PerfStatsNone
+	case *args.PerfStats == 1:
+		return sqlcmd.PerfStatsColon
+	}
+	return sqlcmd.PerfStatsText
+}
+
 func (args *SQLCmdArguments) getControlCharacterBehavior() sqlcmd.ControlCharacterBehavior {
 	if args.RemoveControlCharacters == nil {
 		return sqlcmd.ControlIgnore
@@ -129,8 +142,18 @@ const (
 	disableCmdAndWarn       = "disable-cmd-and-warn"
 	listServers             = "list-servers"
 	removeControlCharacters = "remove-control-characters"
+	format                  = "format"
+	perfStats               = "print-statistics"
+	errorsFormat            = "errors-format"
 )
 
+// errorsFormats are the values accepted by --errors-format
+var errorsFormats = []string{"text", "json"}
+
+// outputFormats are the built-in values accepted by --format. Formats added by
+// sqlcmd.RegisterFormatter are accepted too.
+var outputFormats = []string{"horizontal", "vertical", "ascii", "json", "csv", "markdown", "html", "insert", "parquet", "xlsx"}
+
 func encryptConnectionAllowsTLS(value string) bool {
 	switch strings.ToLower(value) {
 	case "s", "strict", "m", "mandatory", "true", "t", "yes", "1":
@@ -155,6 +178,8 @@ func (a *SQLCmdArguments) Validate(c *cobra.Command) (err error) {
 			err = mutuallyExclusiveError("i", `-Q/-q`)
 		case a.Vertical && a.Ascii:
 			err = mutuallyExclusiveError("--vertical", "--ascii")
+		case a.Format != "" && (a.Vertical || a.Ascii):
+			err = mutuallyExclusiveError("--format", "--vertical/--ascii")
 		case a.UseTrustedConnection && (len(a.UserName) > 0 || len(a.Password) > 0):
 			err = mutuallyExclusiveError("-E", `-U/-P`)
 		case a.UseAad && len(a.AuthenticationMethod) > 0:
@@ -174,6 +199,10 @@ func (a *SQLCmdArguments) Validate(c *cobra.Command) (err error) {
 			err = rangeParameterError("-y", fmt.Sprint(*a.VariableTypeWidth), 0, 8000, true)
 		case a.QueryTimeout < 0 || a.QueryTimeout > 65534:
 			err = rangeParameterError("-t", fmt.Sprint(a.QueryTimeout), 0, 65534, true)
+		case a.CodePage != "" && !validCodePage(a.CodePage):
+			err = localizer.Errorf(`'-f %s': Code page has to be a supported Windows code page number, as codepage or i:codepage[,o:codepage].`, a.CodePage)
+		case a.UnicodeOutputFile && setsOutputCodePage(a.CodePage):
+			err = mutuallyExclusiveError("-u", "-f")
 		case a.ServerCertificate != "" && !encryptConnectionAllowsTLS(a.EncryptConnection):
 			err = localizer.Errorf("The -J parameter requires encryption to be enabled (-N true, -N mandatory, or -N strict).")
 		}
@@ -363,6 +392,7 @@ func checkDefaultValue(args []string, i int) (val string) {
 		'k': "0",
 		'L': "|", // | is the sentinel for no value since users are unlikely to use it. It's "reserved" in most shells
 		'X': "0",
+		'p': "0",
 	}
 	if isFlag(args[i]) && len(args[i]) == 2 && (len(args) == i+1 || args[i+1][0] == '-') {
 		if v, ok := flags[rune(args[i][1])]; ok {
@@ -426,6 +456,7 @@ func SetScreenWidthFlags(args *SQLCmdArguments, rootCmd *cobra.Command) {
 	args.DisableCmd = getOptionalIntArgument(rootCmd, disableCmdAndWarn)
 	args.ErrorsToStderr = getOptionalIntArgument(rootCmd, errorsToStderr)
 	args.RemoveControlCharacters = getOptionalIntArgument(rootCmd, removeControlCharacters)
+	args.PerfStats = getOptionalIntArgument(rootCmd, perfStats)
 }
 
 func setFlags(rootCmd *cobra.Command, args *SQLCmdArguments) {
@@ -484,17 +515,20 @@ func setFlags(rootCmd *cobra.Command, args *SQLCmdArguments) {
 	//rootCmd.Flags().Lookup(encryptConnection).NoOptDefVal = "true"
 	rootCmd.Flags().BoolVarP(&args.Vertical, "vertical", "", false, localizer.Sprintf("Prints the output in vertical format. This option sets the sqlcmd scripting variable %s to '%s'. The default is false", sqlcmd.SQLCMDFORMAT, "vert"))
 	rootCmd.Flags().BoolVarP(&args.Ascii, "ascii", "", false, localizer.Sprintf("Prints the output in ASCII table format. This option sets the sqlcmd scripting variable %s to '%s'. The default is false", sqlcmd.SQLCMDFORMAT, "ascii"))
+	rootCmd.Flags().StringVar(&args.Format, format, "", localizer.Sprintf("Specifies the output format. One of: %s. This option sets the sqlcmd scripting variable %s", strings.Join(outputFormats, ", "), sqlcmd.SQLCMDFORMAT))
 
 	_ = rootCmd.Flags().IntP(errorsToStderr, "r", -1, localizer.Sprintf("%s Redirects error messages with severity >= 11 output to stderr. Pass 1 to to redirect all errors including PRINT.", "-r[0 | 1]"))
 	rootCmd.Flags().IntVar(&args.DriverLoggingLevel, "driver-logging-level", 0, localizer.Sprintf("Level of mssql driver messages to print"))
 	rootCmd.Flags().BoolVarP(&args.ExitOnError, "exit-on-error", "b", false, localizer.Sprintf("Specifies that sqlcmd exits and returns a %s value when an error occurs", localizer.DosErrorLevel))
 	rootCmd.Flags().IntVarP(&args.ErrorLevel, "error-level", "m", 0, localizer.Sprintf("Controls which error messages are sent to %s. Messages that have severity level greater than or equal to this level are sent", localizer.StdoutName))
+	rootCmd.Flags().StringVar(&args.ErrorsFormat, errorsFormat, "text", localizer.Sprintf("Specifies how errors and messages are reported. One of: %s. %s writes each error and message to stderr as a JSON object on its own line", strings.Join(errorsFormats, ", "), "json"))
 	rootCmd.Flags().BoolVarP(&args.RawErrors, "raw-errors", "j", false, localizer.Sprintf("Do not strip the \"mssql: \" prefix from error messages"))
 
 	//Need to decide on short of Header , as "h" is already used in help command in Cobra
 	rootCmd.Flags().IntVarP(&args.Headers, "headers", "h", 0, localizer.Sprintf("Specifies the number of rows to print between the column headings. Use -h-1 to specify that headers not be printed"))
 
 	rootCmd.Flags().BoolVarP(&args.UnicodeOutputFile, "unicode-output-file", "u", false, localizer.Sprintf("Specifies that all output files are encoded with little-endian Unicode"))
+	rootCmd.Flags().StringVarP(&args.CodePage, "code-page", "f", "", localizer.Sprintf("Specifies the code page of input and output files as %s. For example, %s reads Windows-1252 scripts and writes UTF-8 with a byte order mark", "codepage | i:codepage[,o:codepage]", "i:1252,o:65001"))
 	rootCmd.Flags().StringVarP(&args.ColumnSeparator, "column-separator", "s", "", localizer.Sprintf("Specifies the column separator character. Sets the %s variable.", localizer.ColSeparatorVar))
 	rootCmd.Flags().BoolVarP(&args.TrimSpaces, "trim-spaces", "W", false, localizer.Sprintf("Remove trailing spaces from a column"))
 	_ = rootCmd.Flags().BoolP("multi-subnet-failover", "M", false, localizer.Sprintf("Provided for backward compatibility. Sqlcmd always optimizes detection of the active replica of a SQL Failover Cluster"))
@@ -511,6 +545,7 @@ func setFlags(rootCmd *cobra.Command, args *SQLCmdArguments) {
 	rootCmd.Flags().BoolVarP(&args.DedicatedAdminConnection, "dedicated-admin-connection", "A", false, localizer.Sprintf("Dedicated administrator connection"))
 	_ = rootCmd.Flags().BoolP("enable-quoted-identifiers", "I", true, localizer.Sprintf("Provided for backward compatibility. Quoted identifiers are always enabled"))
 	_ = rootCmd.Flags().BoolP("client-regional-setting", "R", false, localizer.Sprintf("Provided for backward compatibility. Client regional settings are not used"))
+	_ = rootCmd.Flags().IntP(perfStats, "p", 0, localizer.Sprintf("%s Prints performance statistics after each batch. Pass 1 to print them as colon-separated values.", "-p[1]"))
 	_ = rootCmd.Flags().IntP(removeControlCharacters, "k", 0, localizer.Sprintf("%s Remove control characters from output. Pass 1 to substitute a space per character, 2 for a space per consecutive characters", "-k [1|2]"))
 	rootCmd.Flags().BoolVarP(&args.EchoInput, "echo-input", "e", false, localizer.Sprintf("Echo input"))
 	rootCmd.Flags().IntVarP(&args.QueryTimeout, "query-timeout", "t", 0, "Query timeout")
@@ -573,6 +608,14 @@ func normalizeFlags(cmd *cobra.Command) error {
 				err = invalidParameterError("-L", v, "c")
 				return pflag.NormalizedName("")
 			}
+		case perfStats:
+			switch v {
+			case "0", "1":
+				return pflag.NormalizedName(name)
+			default:
+				err = invalidParameterError("-p", v, "1")
+				return pflag.NormalizedName("")
+			}
 		case removeControlCharacters:
 			switch v {
 			case "0", "1", "2":
@@ -581,6 +624,19 @@ func normalizeFlags(cmd *cobra.Command) error {
 				err = invalidParameterError("-k", v, "1", "2")
 				return pflag.NormalizedName("")
 			}
+		case errorsFormat:
+			if !slices.Contains(errorsFormats, strings.ToLower(v)) {
+				err = invalidParameterError("--errors-format", v, errorsFormats...)
+				return pflag.NormalizedName("")
+			}
+			return pflag.NormalizedName(name)
+		case format:
+			formats := append(slices.Clone(outputFormats), sqlcmd.RegisteredFormatters()...)
+			if !slices.Contains(formats, strings.ToLower(v)) {
+				err = invalidParameterError("--format", v, formats...)
+				return pflag.NormalizedName("")
+			}
+			return pflag.NormalizedName(name)
 		}
 
 		return pflag.NormalizedName(name)
@@ -596,6 +652,17 @@ var invalidArgRegexp = regexp.MustCompile(`invalid argument \"(.*)\" for \"(-.),
 var missingArgRegexp = regexp.MustCompile(`flag needs an argument: '.' in (-.)`)
 var unknownArgRegexp = regexp.MustCompile(`unknown shorthand flag: '(.)' in -.`)
 
+func validCodePage(arg string) bool {
+	_, _, err := sqlcmd.ParseCodepages(arg)
+	return err == nil
+}
+
+// setsOutputCodePage returns true if the -f argument sets the code page of output files
+func setsOutputCodePage(arg string) bool {
+	_, output, err := sqlcmd.ParseCodepages(arg)
+	return err == nil && output != nil
+}
+
 func rangeParameterError(flag string, value string, min int, max int, inclusive bool) error {
 	if inclusive {
 		return localizer.Errorf(`'%s %s': value must be greater than or equal to %#v and less than or equal to %#v.`, flag, value, min, max)
@@ -725,7 +792,7 @@ func setVars(vars *sqlcmd.Variables, args *SQLCmdArguments) {
 			if a.Ascii {
 				return "ascii"
 			}
-			return ""
+			return strings.ToLower(a.Format)
 		},
 	}
 	for varname, set := range varmap {
@@ -858,11 +925,19 @@ func run(vars *sqlcmd.Variables, args *SQLCmdArguments) (int, error) {
 	s.SetupCloseHandler()
 	defer s.StopCloseHandler()
 	s.UnicodeOutputFile = args.UnicodeOutputFile
+	if args.CodePage != "" {
+		// Validate has already checked the code pages
+		s.InputEncoding, s.OutputEncoding, _ = sqlcmd.ParseCodepages(args.CodePage)
+	}
 
 	if args.DisableCmd != nil {
 		s.Cmd.DisableSysCommands(args.errorOnBlockedCmd())
 	}
 	s.EchoInput = args.EchoInput
+	s.PerfStats = args.getPerfStatsFormat()
+	if strings.EqualFold(args.ErrorsFormat, "json") {
+		s.ErrorsFormat = sqlcmd.ErrorsFormatJSON
+	}
 	if args.BatchTerminator != "GO" {
 		err = s.Cmd.SetBatchTerminator(args.BatchTerminator)
 		if err != nil {
@@ -953,80 +1028,22 @@ func run(vars *sqlcmd.Variables, args *SQLCmdArguments) (int, error) {
 			}
 		}
 	}
+	_ = s.RunCommand(s.Cmd["TEE"], []string{"OFF"})
 	s.SetOutput(nil)
 	s.SetError(nil)
+	s.SetPerfTrace(nil)
 	return s.Exitcode, err
 }
 
 func listLocalServers() {
-	bmsg := []byte{byte(msdsn.BrowserAllInstances)}
-	resp := make([]byte, 16*1024-1)
-	dialer := &net.Dialer{}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	ctx, cancel := context.WithTimeout(context.Background(), browser.DefaultTimeout)
 	defer cancel()
-	conn, err := dialer.DialContext(ctx, "udp", ":1434")
-	// silently ignore failures to connect, same as ODBC
+	data, err := browser.ListInstances(ctx, browser.LocalAddress)
+	// silently ignore failures to reach the browser service, same as ODBC
 	if err != nil {
 		return
 	}
-	defer conn.Close()
-	dl, _ := ctx.Deadline()
-	_ = conn.SetDeadline(dl)
-	_, err = conn.Write(bmsg)
-	if err != nil {
-		if !errors.Is(err, os.ErrDeadlineExceeded) {
-			fmt.Println(err)
-		}
-		return
-	}
-	read, err := conn.Read(resp)
-	if err != nil {
-		if !errors.Is(err, os.ErrDeadlineExceeded) {
-			fmt.Println(err)
-		}
-		return
-	}
-
-	data := parseInstances(resp[:read])
-	instances := make([]string, 0, len(data))
-	for s := range data {
-		if s == "MSSQLSERVER" {
-
-			instances = append(instances, "(local)", data[s]["ServerName"])
-		} else {
-			instances = append(instances, fmt.Sprintf(`%s\%s`, data[s]["ServerName"], s))
-		}
-	}
-	for _, s := range instances {
+	for _, s := range browser.ServerNames(data) {
 		fmt.Println("  ", s)
 	}
 }
-
-func parseInstances(msg []byte) msdsn.BrowserData {
-	results := msdsn.BrowserData{}
-	if len(msg) > 3 && msg[0] == 5 {
-		out_s := string(msg[3:])
-		tokens := strings.Split(out_s, ";")
-		instdict := map[string]string{}
-		got_name := false
-		var name string
-		for _, token := range tokens {
-			if got_name {
-				instdict[name] = token
-				got_name = false
-			} else {
-				name = token
-				if len(name) == 0 {
-					if len(instdict) == 0 {
-						break
-					}
-					results[strings.ToUpper(instdict["InstanceName"])] = instdict
-					instdict = map[string]string{}
-					continue
-				}
-				got_name = true
-			}
-		}
-	}
-	return results
-}
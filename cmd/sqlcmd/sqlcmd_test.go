@@ -5,9 +5,11 @@ package sqlcmd
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 
@@ -99,6 +101,24 @@ func TestValidCommandLineToArgsConversion(t *testing.T) {
 		{[]string{"-k", "-X", "-r", "-z", "something"}, func(args SQLCmdArguments) bool {
 			return args.warnOnBlockedCmd() && !args.useEnvVars() && args.getControlCharacterBehavior() == sqlcmd.ControlRemove && *args.ErrorsToStderr == 0 && args.ChangePassword == "something"
 		}},
+		{[]string{"-p"}, func(args SQLCmdArguments) bool {
+			return args.getPerfStatsFormat() == sqlcmd.PerfStatsText
+		}},
+		{[]string{"-f", "i:1252,o:65001", "-u=false"}, func(args SQLCmdArguments) bool {
+			return args.CodePage == "i:1252,o:65001"
+		}},
+		{[]string{"-u", "-f", "i:1252"}, func(args SQLCmdArguments) bool {
+			return args.CodePage == "i:1252" && args.UnicodeOutputFile
+		}},
+		{[]string{"--errors-format", "json"}, func(args SQLCmdArguments) bool {
+			return args.ErrorsFormat == "json"
+		}},
+		{[]string{"-p", "1"}, func(args SQLCmdArguments) bool {
+			return args.getPerfStatsFormat() == sqlcmd.PerfStatsColon
+		}},
+		{[]string{"-C"}, func(args SQLCmdArguments) bool {
+			return args.getPerfStatsFormat() == sqlcmd.PerfStatsNone
+		}},
 		{[]string{"-N"}, func(args SQLCmdArguments) bool {
 			return args.EncryptConnection == "true"
 		}},
@@ -129,6 +149,16 @@ func TestValidCommandLineToArgsConversion(t *testing.T) {
 		{[]string{"--raw-errors"}, func(args SQLCmdArguments) bool {
 			return args.RawErrors
 		}},
+		{[]string{"--format", "json"}, func(args SQLCmdArguments) bool {
+			return args.Format == "json"
+		}},
+		{[]string{"--format", "Cmd-Test"}, func(args SQLCmdArguments) bool {
+			return args.Format == "Cmd-Test"
+		}},
+	}
+	// formats added by RegisterFormatter are accepted by --format
+	if !slices.Contains(sqlcmd.RegisteredFormatters(), "cmd-test") {
+		sqlcmd.RegisterFormatter("cmd-test", sqlcmd.NewSQLCmdJsonFormatter)
 	}
 
 	for _, test := range commands {
@@ -173,6 +203,10 @@ func TestInvalidCommandLine(t *testing.T) {
 		{[]string{"-L", "-q", `"select 1"`}, "The -L parameter can not be used in combination with other parameters."},
 		{[]string{"-i", "foo.sql", "-q", `"select 1"`}, "The i and the -Q/-q options are mutually exclusive."},
 		{[]string{"-r", "5"}, "'-r 5': Unexpected argument. Argument value has to be one of [0 1]."},
+		{[]string{"-f", "1253x"}, "'-f 1253x': Code page has to be a supported Windows code page number, as codepage or i:codepage[,o:codepage]."},
+		{[]string{"-u", "-f", "o:1252"}, "The -u and the -f options are mutually exclusive."},
+		{[]string{"--errors-format", "xml"}, "'--errors-format xml': Unexpected argument. Argument value has to be one of [text json]."},
+		{[]string{"-p", "2"}, "'-p 2': Unexpected argument. Argument value has to be 1."},
 		{[]string{"-w", "x"}, "'-w x': value must be greater than 8 and less than 65536."},
 		{[]string{"-y", "111111"}, "'-y 111111': value must be greater than or equal to 0 and less than or equal to 8000."},
 		{[]string{"-Y", "-2"}, "'-Y -2': value must be greater than or equal to 0 and less than or equal to 8000."},
@@ -184,6 +218,8 @@ func TestInvalidCommandLine(t *testing.T) {
 		{[]string{"-N", "optional", "-J", "/path/to/cert.pem"}, "The -J parameter requires encryption to be enabled (-N true, -N mandatory, or -N strict)."},
 		{[]string{"-N", "disable", "-J", "/path/to/cert.pem"}, "The -J parameter requires encryption to be enabled (-N true, -N mandatory, or -N strict)."},
 		{[]string{"-N", "strict", "-F", "myserver.domain.com", "-J", "/path/to/cert.pem"}, "The -F and the -J options are mutually exclusive."},
+		{[]string{"--format", "yaml"}, "'--format yaml': Unexpected argument. Argument value has to be one of " + fmt.Sprint(append(slices.Clone(outputFormats), sqlcmd.RegisteredFormatters()...)) + "."},
+		{[]string{"--format", "json", "--vertical"}, "The --format and the --vertical/--ascii options are mutually exclusive."},
 	}
 
 	for _, test := range commands {
@@ -590,6 +626,11 @@ func TestConvertOsArgs(t *testing.T) {
 			[]string{"-r", "1", "-X", "-k", "-C"},
 			[]string{"-r", "1", "-X", "0", "-k", "0", "-C"},
 		},
+		{
+			"-p without a value",
+			[]string{"-p", "-C"},
+			[]string{"-p", "0", "-C"},
+		},
 		{
 			"-i followed by flags without spaces",
 			[]string{"-i", "a.sql", "-V10", "-C"},
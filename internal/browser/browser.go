@@ -0,0 +1,110 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+// Package browser discovers SQL Server instances through the SQL Server Browser service
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// LocalAddress is the address of the SQL Server Browser service of the local computer
+const LocalAddress = ":1434"
+
+// DefaultTimeout is how long sqlcmd waits for the SQL Server Browser service to respond
+const DefaultTimeout = 30 * time.Second
+
+// responseSize is the largest response the SQL Server Browser service sends
+const responseSize = 16*1024 - 1
+
+// ListInstances asks the SQL Server Browser service at address for the instances it knows about.
+// The deadline of ctx bounds the wait for the response. Like ODBC sqlcmd, no instances are
+// returned without an error when the service can't be reached or doesn't respond in time.
+func ListInstances(ctx context.Context, address string) (msdsn.BrowserData, error) {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		return msdsn.BrowserData{}, nil
+	}
+	defer conn.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	if _, err = conn.Write([]byte{byte(msdsn.BrowserAllInstances)}); err != nil {
+		return noResponse(err)
+	}
+	resp := make([]byte, responseSize)
+	read, err := conn.Read(resp)
+	if err != nil {
+		return noResponse(err)
+	}
+	return ParseInstances(resp[:read]), nil
+}
+
+// noResponse returns no instances when err means the service isn't there, and err otherwise
+func noResponse(err error) (msdsn.BrowserData, error) {
+	if errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, syscall.ECONNREFUSED) {
+		return msdsn.BrowserData{}, nil
+	}
+	return nil, err
+}
+
+// ParseInstances parses a SVR_RESP message of the SQL Server Browser service into the
+// properties of each instance, keyed by the upper case instance name
+func ParseInstances(msg []byte) msdsn.BrowserData {
+	results := msdsn.BrowserData{}
+	if len(msg) > 3 && msg[0] == 5 {
+		out_s := string(msg[3:])
+		tokens := strings.Split(out_s, ";")
+		instdict := map[string]string{}
+		got_name := false
+		var name string
+		for _, token := range tokens {
+			if got_name {
+				instdict[name] = token
+				got_name = false
+			} else {
+				name = token
+				if len(name) == 0 {
+					if len(instdict) == 0 {
+						break
+					}
+					results[strings.ToUpper(instdict["InstanceName"])] = instdict
+					instdict = map[string]string{}
+					continue
+				}
+				got_name = true
+			}
+		}
+	}
+	return results
+}
+
+// ServerNames returns the names to connect to the instances with, sorted by instance name.
+// The default instance is listed as both (local) and the name of its server.
+func ServerNames(data msdsn.BrowserData) []string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	names := make([]string, 0, len(data))
+	for _, k := range keys {
+		if k == "MSSQLSERVER" {
+			names = append(names, "(local)", data[k]["ServerName"])
+		} else {
+			names = append(names, fmt.Sprintf(`%s\%s`, data[k]["ServerName"], k))
+		}
+	}
+	return names
+}
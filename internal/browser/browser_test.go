@@ -0,0 +1,80 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package browser
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testInstances = "ServerName;HOST1;InstanceName;MSSQLSERVER;IsClustered;No;Version;16.0.1000.6;tcp;1433;;" +
+	"ServerName;HOST1;InstanceName;SQLEXPRESS;IsClustered;No;Version;16.0.1000.6;tcp;50123;;"
+
+// browserResponse builds the SVR_RESP message the SQL Server Browser service sends for instances
+func browserResponse(instances string) []byte {
+	msg := []byte{5, 0, 0}
+	binary.LittleEndian.PutUint16(msg[1:], uint16(len(instances)))
+	return append(msg, instances...)
+}
+
+// startResponder listens on a local UDP port in place of the SQL Server Browser service and
+// answers each request for all instances with response. It returns the address to query.
+func startResponder(t *testing.T, response []byte) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err, "ListenPacket")
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		req := make([]byte, 16)
+		for {
+			n, addr, err := conn.ReadFrom(req)
+			if err != nil {
+				return
+			}
+			if n == 1 && req[0] == byte(msdsn.BrowserAllInstances) {
+				_, _ = conn.WriteTo(response, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestListInstances(t *testing.T) {
+	address := startResponder(t, browserResponse(testInstances))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	data, err := ListInstances(ctx, address)
+	if assert.NoError(t, err, "ListInstances") {
+		assert.Len(t, data, 2, "instances")
+		assert.Equal(t, "50123", data["SQLEXPRESS"]["tcp"], "port of SQLEXPRESS")
+		assert.Equal(t, []string{"(local)", "HOST1", `HOST1\SQLEXPRESS`}, ServerNames(data), "ServerNames")
+	}
+}
+
+func TestListInstancesWithoutResponse(t *testing.T) {
+	// a listener that never answers
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err, "ListenPacket")
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	data, err := ListInstances(ctx, conn.LocalAddr().String())
+	assert.NoError(t, err, "no response is not an error")
+	assert.Empty(t, data, "instances")
+}
+
+func TestParseInstances(t *testing.T) {
+	assert.Empty(t, ParseInstances(nil), "empty message")
+	assert.Empty(t, ParseInstances([]byte{4, 0, 0, 'a'}), "not a SVR_RESP message")
+	data := ParseInstances(browserResponse("ServerName;HOST2;InstanceName;named;tcp;1500;;"))
+	assert.Equal(t, msdsn.BrowserData{"NAMED": {"ServerName": "HOST2", "InstanceName": "named", "tcp": "1500"}}, data)
+	assert.Equal(t, []string{`HOST2\NAMED`}, ServerNames(data), "ServerNames")
+}
@@ -63,7 +63,12 @@ func (c *chromaColorizer) Write(w io.Writer, s string, scheme string, t TextType
 	colorize := scheme != "" && style != nil
 	// only colorize if w is a terminal and it's not redirected, or if forceColor is set
 	if colorize && !c.forceColor {
-		if f, ok := w.(*os.File); ok {
+		console := w
+		// writers that copy the console output to a file are colorized like the console
+		if u, ok := w.(interface{ Unwrap() io.Writer }); ok {
+			console = u.Unwrap()
+		}
+		if f, ok := console.(*os.File); ok {
 			if f == os.Stdout || f == os.Stderr {
 				i, _ := f.Stat()
 				colorize = (i.Mode() & os.ModeCharDevice) == os.ModeCharDevice
@@ -29,6 +29,8 @@ type Batch struct {
 	batchline int
 	// linecount is the total number of batch lines processed in the session
 	linecount uint
+	// startline is the linecount of the first line of the current batch
+	startline uint
 	// varmap tracks the location of expandable variables for the entire batch
 	varmap map[int]string
 	// linevarmap tracks the location of expandable variables on the current line
@@ -165,6 +167,9 @@ parse:
 					b.varmap[v+b.Length+inc] = b.linevarmap[v]
 				}
 			}
+			if b.batchline == 1 {
+				b.startline = b.linecount
+			}
 			// log.Printf(">> appending: `%s`", string(r[st:i]))
 			b.append(b.raw[:i], lineend)
 			b.batchline++
@@ -0,0 +1,153 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/microsoft/go-sqlcmd/internal/localizer"
+)
+
+// blobFileExtension is the extension of the files written by :BLOBOUT
+const blobFileExtension = ".bin"
+
+// blobOutput holds the settings of :BLOBOUT
+type blobOutput struct {
+	dir string
+	// nameColumn is the column that names the files. Files are numbered when it's empty.
+	nameColumn string
+	// files is the number of rows written without a name
+	files int
+}
+
+// blobFile is the value formatters receive in place of a large value that :BLOBOUT wrote to a file.
+// It prints as the name of the file.
+type blobFile string
+
+func (b blobFile) String() string {
+	return string(b)
+}
+
+// blobColumns writes the large values of each row of a result set to files. The values aren't
+// streamed: go-mssqldb reads each value completely before Scan returns.
+type blobColumns struct {
+	out *blobOutput
+	// columns are the indexes of the large columns and raw holds their values
+	columns []int
+	raw     []sql.RawBytes
+	names   []string
+	// name is the index of the column that names the files, or -1 to number them
+	name int
+}
+
+// newBlobColumns finds the large columns of the result set and scans them into raw buffers,
+// so their values are written as the driver returns them instead of being converted to text.
+// The driver still reads each value completely into memory. It returns nil when there are
+// no large columns.
+func (o *blobOutput) newBlobColumns(cols []*sql.ColumnType, row *rowBuffer) (*blobColumns, error) {
+	b := &blobColumns{out: o, name: -1}
+	for i, c := range cols {
+		if o.nameColumn != "" && strings.EqualFold(c.Name(), o.nameColumn) {
+			b.name = i
+			break
+		}
+	}
+	for i, c := range cols {
+		// a large name column names the files instead of being written to one
+		if isLargeVariableType(c) && i != b.name {
+			b.columns = append(b.columns, i)
+			name := c.Name()
+			if name == "" {
+				name = strconv.Itoa(i + 1)
+			}
+			b.names = append(b.names, name)
+		}
+	}
+	if len(b.columns) == 0 {
+		return nil, nil
+	}
+	if o.nameColumn != "" && b.name < 0 {
+		return nil, localizer.Errorf("The :BLOBOUT name column %s is not in the result set", o.nameColumn)
+	}
+	b.raw = make([]sql.RawBytes, len(b.columns))
+	for n, i := range b.columns {
+		row.dest[i] = &b.raw[n]
+	}
+	return b, nil
+}
+
+// write writes the large values of the scanned row to files and replaces them with the file names.
+// columns formats the value of the name column.
+func (b *blobColumns) write(values []interface{}, columns *sqlCmdFormatterType) error {
+	name := ""
+	if b.name >= 0 && values[b.name] != nil {
+		name = blobFileName(columns.formatValue(b.name, values[b.name]))
+	}
+	if name == "" {
+		b.out.files++
+		name = strconv.Itoa(b.out.files)
+	}
+	var err error
+	for n, i := range b.columns {
+		values[i] = nil
+		if b.raw[n] == nil {
+			continue
+		}
+		fileName := name
+		if len(b.columns) > 1 {
+			fileName += "_" + blobFileName(b.names[n])
+		}
+		path, werr := writeBlobFile(b.out.dir, fileName, b.raw[n])
+		if werr != nil {
+			err = InvalidFileError(werr, path)
+			continue
+		}
+		values[i] = blobFile(path)
+	}
+	return err
+}
+
+// writeBlobFile writes a value to a new file in dir and returns its path. Files are never
+// replaced: when the name is taken, by an earlier row or an existing file, a number is
+// added to it, as in report.pdf_2.bin.
+func writeBlobFile(dir string, name string, value []byte) (string, error) {
+	path := filepath.Join(dir, name+blobFileExtension)
+	for n := 2; ; n++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			path = filepath.Join(dir, fmt.Sprintf("%s_%d%s", name, n, blobFileExtension))
+			continue
+		}
+		if err != nil {
+			return path, err
+		}
+		_, err = f.Write(value)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return path, err
+	}
+}
+
+// blobFileName makes a file name from the value of the name column. Characters that
+// aren't allowed in file names, including path separators, are replaced by _.
+func blobFileName(value string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(value))
+	if name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,160 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobOutCommand(t *testing.T) {
+	s := New(nil, "", InitializeVariables(false))
+	dir := filepath.Join(t.TempDir(), "files")
+	assert.EqualError(t, blobOutCommand(s, []string{""}, 1), InvalidCommandError("BLOBOUT", 1).Error())
+	assert.EqualError(t, blobOutCommand(s, []string{dir + " name extra"}, 1), InvalidCommandError("BLOBOUT", 1).Error())
+	require.NoError(t, blobOutCommand(s, []string{dir + " FileName"}, 1))
+	assert.DirExists(t, dir, ":BLOBOUT creates the folder")
+	assert.Equal(t, &blobOutput{dir: dir, nameColumn: "FileName"}, s.blobOut)
+	require.NoError(t, blobOutCommand(s, []string{"off"}, 1))
+	assert.Nil(t, s.blobOut)
+}
+
+func blobTestColumns(columns ...[]string) []*sql.ColumnType {
+	cols := make([]*sql.ColumnType, len(columns))
+	for i, c := range columns {
+		cols[i] = new(sql.ColumnType)
+		setColumnInfo(cols[i], c[0], c[1])
+		if c[1] == "VARBINARY" || c[1] == "NVARCHAR" {
+			setColumnLength(cols[i], 2147483647)
+		}
+	}
+	return cols
+}
+
+func TestBlobColumnsWrite(t *testing.T) {
+	dir := t.TempDir()
+	o := &blobOutput{dir: dir, nameColumn: "name"}
+	cols := blobTestColumns([]string{"id", "INT"}, []string{"name", "NVARCHAR"}, []string{"data", "VARBINARY"})
+	columns := &sqlCmdFormatterType{vars: InitializeVariables(false)}
+	columns.columnDetails, _ = calcColumnDetails(cols, 0, 0)
+	row := newRowBuffer(len(cols))
+	b, err := o.newBlobColumns(cols, row)
+	require.NoError(t, err)
+	require.NotNil(t, b)
+	assert.Equal(t, []int{2}, b.columns, "the name column isn't written to a file")
+
+	row.values[0], row.values[1], b.raw[0] = int64(1), "../report.pdf", sql.RawBytes("%PDF")
+	require.NoError(t, b.write(row.values, columns))
+	path := filepath.Join(dir, ".._report.pdf.bin")
+	assert.Equal(t, blobFile(path), row.values[2], "the formatter receives the file name")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "%PDF", string(content))
+
+	row.values[0], row.values[1], b.raw[0] = int64(2), nil, nil
+	require.NoError(t, b.write(row.values, columns))
+	assert.Nil(t, row.values[2], "NULL values aren't written")
+	assert.Equal(t, 1, o.files, "rows without a name are numbered")
+
+	o.nameColumn = "missing"
+	_, err = o.newBlobColumns(cols, newRowBuffer(len(cols)))
+	assert.Error(t, err, "the name column must be in the result set")
+	b, err = o.newBlobColumns(blobTestColumns([]string{"id", "INT"}), newRowBuffer(1))
+	assert.NoError(t, err)
+	assert.Nil(t, b, "result sets without large columns aren't changed")
+}
+
+func TestBlobColumnsWriteNumberedFiles(t *testing.T) {
+	dir := t.TempDir()
+	o := &blobOutput{dir: dir}
+	cols := blobTestColumns([]string{"doc", "NVARCHAR"}, []string{"", "VARBINARY"})
+	columns := &sqlCmdFormatterType{vars: InitializeVariables(false)}
+	columns.columnDetails, _ = calcColumnDetails(cols, 0, 0)
+	row := newRowBuffer(len(cols))
+	b, err := o.newBlobColumns(cols, row)
+	require.NoError(t, err)
+	b.raw[0], b.raw[1] = sql.RawBytes("text"), sql.RawBytes{1, 2}
+	require.NoError(t, b.write(row.values, columns))
+	assert.Equal(t, []interface{}{blobFile(filepath.Join(dir, "1_doc.bin")), blobFile(filepath.Join(dir, "1_2.bin"))}, row.values,
+		"each large column of a row has its own file")
+	assert.FileExists(t, filepath.Join(dir, "1_2.bin"))
+}
+
+func TestBlobColumnsWriteKeepsExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.bin"), []byte("kept"), 0o644))
+	o := &blobOutput{dir: dir, nameColumn: "name"}
+	cols := blobTestColumns([]string{"name", "NVARCHAR"}, []string{"data", "VARBINARY"})
+	columns := &sqlCmdFormatterType{vars: InitializeVariables(false)}
+	columns.columnDetails, _ = calcColumnDetails(cols, 0, 0)
+	row := newRowBuffer(len(cols))
+	b, err := o.newBlobColumns(cols, row)
+	require.NoError(t, err)
+
+	rows := []struct {
+		name interface{}
+		file string
+	}{
+		{"a/b", "a_b.bin"},
+		{"a_b", "a_b_2.bin"},
+		{"a/b", "a_b_3.bin"},
+		{"1", "1.bin"},
+		{nil, "1_2.bin"},
+		{"old", "old_2.bin"},
+	}
+	for i, r := range rows {
+		row.values[0], b.raw[0] = r.name, sql.RawBytes(strconv.Itoa(i))
+		require.NoError(t, b.write(row.values, columns))
+		path := filepath.Join(dir, r.file)
+		assert.Equal(t, blobFile(path), row.values[1], "row %d", i)
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(i), string(content), "row %d", i)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "old.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, "kept", string(content), "existing files aren't replaced")
+}
+
+// scanningFormatter is a formatter that reads the rows itself
+type scanningFormatter struct {
+	Formatter
+}
+
+func (f scanningFormatter) AddRow(rows *sql.Rows) string { return "" }
+
+func TestBlobOutUnsupportedFormatter(t *testing.T) {
+	vars := InitializeVariables(false)
+	s := New(nil, "", vars)
+	s.Format = scanningFormatter{NewSQLCmdDefaultFormatter(vars, false, ControlIgnore)}
+	assert.NoError(t, s.blobOutUnsupported(), "no error while :BLOBOUT is off")
+	require.NoError(t, blobOutCommand(s, []string{t.TempDir()}, 1))
+	assert.EqualError(t, s.blobOutUnsupported(), "The large values were not written to files. :BLOBOUT requires a formatter that implements AddValues")
+	s.Format = NewSQLCmdDefaultFormatter(vars, false, ControlIgnore)
+	assert.NoError(t, s.blobOutUnsupported(), "the built-in formatters receive rows from sqlcmd")
+}
+
+func TestBlobFileName(t *testing.T) {
+	assert.Equal(t, "a_b_c_.png", blobFileName(` a/b\c:.png `))
+	assert.Equal(t, "", blobFileName(".."))
+	assert.Equal(t, "photo 1", blobFileName("photo 1"))
+}
+
+func TestBlobOutQuery(t *testing.T) {
+	s, buf := setupSqlCmdWithMemoryOutput(t)
+	defer buf.Close()
+	dir := t.TempDir()
+	err := runSqlCmd(t, s, []string{":BLOBOUT " + dir + " name", "select N'logo.png' as name, cast(0x89504E47 as varbinary(max)) as data", "GO"})
+	assert.NoError(t, err, "runSqlCmd returned error")
+	content, err := os.ReadFile(filepath.Join(dir, "logo.png.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x89, 'P', 'N', 'G'}, content)
+	assert.Contains(t, buf.buf.String(), filepath.Join(dir, "logo.png.bin"), "the file name is printed in place of the value")
+}
@@ -0,0 +1,135 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// ErrInvalidCodepage is returned by ParseCodepages for an unsupported code page
+var ErrInvalidCodepage = errors.New("invalid code page")
+
+// codepages maps the Windows code page numbers accepted by -f to their encodings.
+// Like ODBC sqlcmd, files written as UTF-8 (65001) or UTF-16 (1200, 1201) start with a byte order mark.
+var codepages = map[int]encoding.Encoding{
+	437:   charmap.CodePage437,
+	850:   charmap.CodePage850,
+	852:   charmap.CodePage852,
+	866:   charmap.CodePage866,
+	874:   charmap.Windows874,
+	932:   japanese.ShiftJIS,
+	936:   simplifiedchinese.GBK,
+	949:   korean.EUCKR,
+	950:   traditionalchinese.Big5,
+	1200:  unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	1201:  unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+	1250:  charmap.Windows1250,
+	1251:  charmap.Windows1251,
+	1252:  charmap.Windows1252,
+	1253:  charmap.Windows1253,
+	1254:  charmap.Windows1254,
+	1255:  charmap.Windows1255,
+	1256:  charmap.Windows1256,
+	1257:  charmap.Windows1257,
+	1258:  charmap.Windows1258,
+	20932: japanese.EUCJP,
+	28591: charmap.ISO8859_1,
+	28592: charmap.ISO8859_2,
+	28605: charmap.ISO8859_15,
+	50220: japanese.ISO2022JP,
+	54936: simplifiedchinese.GB18030,
+	65001: unicode.UTF8BOM,
+}
+
+// ParseCodepages parses the argument of -f, which is either a code page used for both input and
+// output or i:codepage[,o:codepage] to set them separately. An encoding is nil when the argument
+// doesn't set it.
+func ParseCodepages(arg string) (input encoding.Encoding, output encoding.Encoding, err error) {
+	parts := strings.Split(arg, ",")
+	if len(parts) > 2 {
+		return nil, nil, ErrInvalidCodepage
+	}
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		prefix := ""
+		if len(part) > 2 && part[1] == ':' {
+			prefix = strings.ToLower(part[:1])
+			part = part[2:]
+		}
+		cp, convErr := strconv.Atoi(part)
+		enc, ok := codepages[cp]
+		if convErr != nil || !ok {
+			return nil, nil, ErrInvalidCodepage
+		}
+		switch {
+		case prefix == "" && len(parts) == 1:
+			input, output = enc, enc
+		case prefix == "i" && input == nil:
+			input = enc
+		case prefix == "o" && output == nil:
+			output = enc
+		default:
+			return nil, nil, ErrInvalidCodepage
+		}
+	}
+	return input, output, nil
+}
+
+// inputDecoder returns the decoder of input files. A byte order mark selects UTF-8 or UTF-16
+// regardless of the input code page.
+func (s *Sqlcmd) inputDecoder() transform.Transformer {
+	if s.InputEncoding == nil {
+		return unicode.BOMOverride(unicode.UTF8.NewDecoder())
+	}
+	return unicode.BOMOverride(s.InputEncoding.NewDecoder())
+}
+
+// outputEncoding returns the encoding of output files, or nil for UTF-8 without conversion
+func (s *Sqlcmd) outputEncoding() encoding.Encoding {
+	switch {
+	case s.OutputEncoding != nil:
+		return s.OutputEncoding
+	case s.UnicodeOutputFile:
+		// ODBC sqlcmd doesn't write a BOM but we will.
+		// Maybe the endian-ness should be configurable.
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	}
+	return nil
+}
+
+// encodedFile converts the text written to a file to another encoding
+type encodedFile struct {
+	*transform.Writer
+	file io.Closer
+}
+
+// Close writes the rest of the converted text and closes the file
+func (e *encodedFile) Close() error {
+	err := e.Writer.Close()
+	if cerr := e.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// encodeFile returns a writer that converts text to the encoding before writing it to f.
+// Characters the encoding can't represent are replaced.
+func encodeFile(f io.WriteCloser, enc encoding.Encoding) io.WriteCloser {
+	if enc == nil {
+		return f
+	}
+	return &encodedFile{Writer: transform.NewWriter(f, encoding.ReplaceUnsupported(enc.NewEncoder())), file: f}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestParseCodepages(t *testing.T) {
+	tests := []struct {
+		arg    string
+		input  encoding.Encoding
+		output encoding.Encoding
+	}{
+		{"1252", charmap.Windows1252, charmap.Windows1252},
+		{"i:1250", charmap.Windows1250, nil},
+		{"O:932", nil, japanese.ShiftJIS},
+		{"i:1252,o:65001", charmap.Windows1252, unicode.UTF8BOM},
+		{"o:1201, i:1252", charmap.Windows1252, unicode.UTF16(unicode.BigEndian, unicode.UseBOM)},
+	}
+	for _, test := range tests {
+		input, output, err := ParseCodepages(test.arg)
+		if assert.NoError(t, err, test.arg) {
+			assert.Equal(t, test.input, input, "input of %s", test.arg)
+			assert.Equal(t, test.output, output, "output of %s", test.arg)
+		}
+	}
+	for _, arg := range []string{"", "1", "utf8", "x:1252", "i:1252,i:1250", "1252,o:65001", "i:1252,o:65001,o:1200"} {
+		_, _, err := ParseCodepages(arg)
+		assert.ErrorIs(t, err, ErrInvalidCodepage, arg)
+	}
+}
+
+func TestIncludeFileCodepage(t *testing.T) {
+	s := New(nil, "", InitializeVariables(false))
+	s.Format = NewSQLCmdDefaultFormatter(s.vars, false, ControlIgnore)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "latin.sql")
+	require.NoError(t, os.WriteFile(file, []byte(":setvar name caf\xe9\n"), 0o644))
+	s.InputEncoding = charmap.Windows1252
+	require.NoError(t, s.IncludeFile(file, false))
+	v, _ := s.vars.Get("name")
+	assert.Equal(t, "café", v, "the file is read as Windows-1252")
+
+	// a byte order mark overrides the input code page
+	b, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(":setvar name naïve\n"))
+	require.NoError(t, err)
+	file = filepath.Join(dir, "utf16.sql")
+	require.NoError(t, os.WriteFile(file, b, 0o644))
+	require.NoError(t, s.IncludeFile(file, false))
+	v, _ = s.vars.Get("name")
+	assert.Equal(t, "naïve", v, "the file is read as UTF-16")
+}
+
+func TestOutputCodepage(t *testing.T) {
+	s := New(nil, "", InitializeVariables(false))
+	s.Format = NewSQLCmdDefaultFormatter(s.vars, false, ControlIgnore)
+	defer s.SetOutput(nil)
+	defer s.SetError(nil)
+	dir := t.TempDir()
+	s.OutputEncoding = charmap.Windows1252
+	out := filepath.Join(dir, "out.txt")
+	require.NoError(t, outCommand(s, []string{out}, 1))
+	_, _ = s.GetOutput().Write([]byte("café €5 ☃"))
+	errFile := filepath.Join(dir, "err.txt")
+	require.NoError(t, errorCommand(s, []string{errFile}, 1))
+	_, _ = s.GetError().Write([]byte("erreur à"))
+	s.SetOutput(nil)
+	s.SetError(nil)
+	b, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "caf\xe9 \x805 \x1a", string(b), "characters missing from the code page are replaced")
+	b, err = os.ReadFile(errFile)
+	require.NoError(t, err)
+	assert.Equal(t, "erreur \xe0", string(b), ":ERROR files use the output code page")
+
+	s.OutputEncoding = unicode.UTF8BOM
+	s.UnicodeOutputFile = true
+	require.NoError(t, outCommand(s, []string{filepath.Join(dir, "out_{batch}.txt")}, 1))
+	_, _ = s.GetOutput().Write([]byte("café"))
+	s.SetOutput(nil)
+	b, err = os.ReadFile(filepath.Join(dir, "out_1.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "\xef\xbb\xbfcafé", string(b), "65001 writes a byte order mark and takes precedence over -u")
+}
@@ -4,17 +4,28 @@
 package sqlcmd
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/microsoft/go-sqlcmd/internal/browser"
 	"github.com/microsoft/go-sqlcmd/internal/color"
-	"golang.org/x/text/encoding/unicode"
-	"golang.org/x/text/transform"
+	"github.com/microsoft/go-sqlcmd/internal/localizer"
+	"golang.org/x/text/encoding"
+)
+
+// browserAddress and browserTimeout set the SQL Server Browser service queried by :SERVERLIST
+var (
+	browserAddress = browser.LocalAddress
+	browserTimeout = browser.DefaultTimeout
 )
 
 // Command defines a sqlcmd action which can be intermixed with the SQL batch
@@ -29,6 +40,12 @@ type Command struct {
 	name string
 	// whether the command is a system command
 	isSystem bool
+	// syntax shows how to use the command in :HELP
+	syntax string
+	// help is the localized description of the command printed by :HELP
+	help string
+	// disabled is true when DisableSysCommands has replaced the action
+	disabled bool
 }
 
 // Commands is the set of sqlcmd command implementations
@@ -41,77 +58,149 @@ func newCommands() Commands {
 			regex:  regexp.MustCompile(`(?im)^[\t ]*?:?EXIT([\( \t]+.*\)*$|$)`),
 			action: exitCommand,
 			name:   "EXIT",
+			syntax: `:EXIT[(statement)]`,
+			help:   localizer.Sprintf("Exits sqlcmd. With parentheses, runs the batch or the statement first and returns its first value as the exit code."),
 		},
 		"QUIT": {
 			regex:  regexp.MustCompile(`(?im)^[\t ]*?:?QUIT(?:[ \t]+(.*$)|$)`),
 			action: quitCommand,
 			name:   "QUIT",
+			syntax: `:QUIT`,
+			help:   localizer.Sprintf("Exits sqlcmd without running the batch."),
 		},
 		"GO": {
 			regex:  regexp.MustCompile(batchTerminatorRegex("GO")),
 			action: goCommand,
 			name:   "GO",
+			syntax: `GO [count]`,
+			help:   localizer.Sprintf("Runs the batch, the given number of times."),
 		},
 		"OUT": {
 			regex:  regexp.MustCompile(`(?im)^[ \t]*:OUT(?:[ \t]+(.*$)|$)`),
 			action: outCommand,
 			name:   "OUT",
+			syntax: `:OUT <filename>|STDERR|STDOUT`,
+			help:   localizer.Sprintf("Writes query results to a file, stderr or stdout."),
 		},
 		"ERROR": {
 			regex:  regexp.MustCompile(`(?im)^[ \t]*:ERROR(?:[ \t]+(.*$)|$)`),
 			action: errorCommand,
 			name:   "ERROR",
+			syntax: `:ERROR <filename>|STDERR|STDOUT`,
+			help:   localizer.Sprintf("Writes error messages to a file, stderr or stdout."),
 		}, "READFILE": {
 			regex:  regexp.MustCompile(`(?im)^[ \t]*:R(?:[ \t]+(.*$)|$)`),
 			action: readFileCommand,
 			name:   "READFILE",
+			syntax: `:R <filename>`,
+			help:   localizer.Sprintf("Reads and runs the statements and commands of a file."),
 		},
 		"SETVAR": {
 			regex:  regexp.MustCompile(`(?im)^[ \t]*:SETVAR(?:[ \t]+(.*$)|$)`),
 			action: setVarCommand,
 			name:   "SETVAR",
+			syntax: `:SETVAR <var> ["value"]`,
+			help:   localizer.Sprintf("Sets a scripting variable. Without a value, removes it."),
 		},
 		"LISTVAR": {
 			regex:  regexp.MustCompile(`(?im)^[\t ]*?:LISTVAR(?:[ \t]+(.*$)|$)`),
 			action: listVarCommand,
 			name:   "LISTVAR",
+			syntax: `:LISTVAR`,
+			help:   localizer.Sprintf("Lists the scripting variables."),
 		},
 		"RESET": {
 			regex:  regexp.MustCompile(`(?im)^[ \t]*?:?RESET(?:[ \t]+(.*$)|$)`),
 			action: resetCommand,
 			name:   "RESET",
+			syntax: `:RESET`,
+			help:   localizer.Sprintf("Discards the batch."),
 		},
 		"LIST": {
 			regex:  regexp.MustCompile(`(?im)^[ \t]*:LIST(?:[ \t]+(.*$)|$)`),
 			action: listCommand,
 			name:   "LIST",
+			syntax: `:LIST [COLOR]`,
+			help:   localizer.Sprintf("Prints the batch. COLOR prints a sample of each color scheme."),
 		},
 		"CONNECT": {
 			regex:  regexp.MustCompile(`(?im)^[ \t]*:CONNECT(?:[ \t]+(.*$)|$)`),
 			action: connectCommand,
 			name:   "CONNECT",
+			syntax: `:CONNECT server[\instance] [-l timeout] [-U user [-P password]] [-D database] [-G method]`,
+			help:   localizer.Sprintf("Connects to a SQL Server instance."),
+		},
+		"DESCRIBE": {
+			regex:  regexp.MustCompile(`(?im)^[ \t]*:DESCRIBE(?:[ \t]+(.*$)|$)`),
+			action: describeCommand,
+			name:   "DESCRIBE",
+			syntax: `:DESCRIBE <object>`,
+			help:   localizer.Sprintf("Shows the columns, indexes, foreign keys, triggers and row count of a table or view, or the parameters of a procedure or function."),
 		},
 		"EXEC": {
 			regex:    regexp.MustCompile(`(?im)^[ \t]*?:?!!(.*$)`),
 			action:   execCommand,
 			name:     "EXEC",
+			syntax:   `:!! <command>`,
+			help:     localizer.Sprintf("Runs an operating system command."),
 			isSystem: true,
 		},
 		"EDIT": {
 			regex:    regexp.MustCompile(`(?im)^[\t ]*?:?ED(?:[ \t]+(.*$)|$)`),
 			action:   editCommand,
 			name:     "EDIT",
+			syntax:   `:ED`,
+			help:     localizer.Sprintf("Edits the batch in the editor set by SQLCMDEDITOR."),
 			isSystem: true,
 		},
 		"ONERROR": {
 			regex:  regexp.MustCompile(`(?im)^[\t ]*?:?ON ERROR(?:[ \t]+(.*$)|$)`),
 			action: onerrorCommand,
 			name:   "ONERROR",
+			syntax: `:ON ERROR EXIT|IGNORE`,
+			help:   localizer.Sprintf("Sets whether sqlcmd exits or continues when an error occurs."),
 		},
 		"XML": {
 			regex:  regexp.MustCompile(`(?im)^[\t ]*?:XML(?:[ \t]+(.*$)|$)`),
 			action: xmlCommand,
 			name:   "XML",
+			syntax: `:XML ON [INDENT]|OFF`,
+			help:   localizer.Sprintf("Prints results as XML. INDENT puts each element on its own line."),
+		},
+		"BLOBOUT": {
+			regex:  regexp.MustCompile(`(?im)^[ \t]*:BLOBOUT(?:[ \t]+(.*$)|$)`),
+			action: blobOutCommand,
+			name:   "BLOBOUT",
+			syntax: `:BLOBOUT <folder> [name-column]|OFF`,
+			help:   localizer.Sprintf("Writes the values of large columns to files in a folder, named by the name column."),
+		},
+		"HELP": {
+			regex:  regexp.MustCompile(`(?im)^[ \t]*:HELP(?:[ \t]+(.*$)|$)`),
+			action: helpCommand,
+			name:   "HELP",
+			syntax: `:HELP [command]`,
+			help:   localizer.Sprintf("Lists the sqlcmd commands, or describes one command."),
+		},
+		"PERFTRACE": {
+			regex:  regexp.MustCompile(`(?im)^[ \t]*:PERFTRACE(?:[ \t]+(.*$)|$)`),
+			action: perfTraceCommand,
+			name:   "PERFTRACE",
+			syntax: `:PERFTRACE <filename>|STDERR|STDOUT`,
+			help:   localizer.Sprintf("Writes batch timing and SET STATISTICS messages to a file, stderr or stdout."),
+		},
+		"SERVERLIST": {
+			regex:  regexp.MustCompile(`(?im)^[ \t]*:SERVERLIST(?:[ \t]+(.*$)|$)`),
+			action: serverListCommand,
+			name:   "SERVERLIST",
+			syntax: `:SERVERLIST`,
+			help:   localizer.Sprintf("Lists the SQL Server instances found by the SQL Server Browser service, in the form :CONNECT accepts."),
+		},
+		"TEE": {
+			regex:  regexp.MustCompile(`(?im)^[ \t]*:TEE(?:[ \t]+(.*$)|$)`),
+			action: teeCommand,
+			name:   "TEE",
+			syntax: `:TEE <filename> [APPEND]|OFF`,
+			help:   localizer.Sprintf("Copies the output to a file while still printing it."),
 		},
 	}
 }
@@ -126,8 +215,19 @@ func (c Commands) DisableSysCommands(exitOnCall bool) {
 	for _, cmd := range c {
 		if cmd.isSystem {
 			cmd.action = f
+			cmd.disabled = true
+		}
+	}
+}
+
+// sysCommandsDisabled returns true if DisableSysCommands has disabled the system commands
+func (c Commands) sysCommandsDisabled() bool {
+	for _, cmd := range c {
+		if cmd.isSystem && cmd.disabled {
+			return true
 		}
 	}
+	return false
 }
 
 func (c Commands) matchCommand(line string) (*Command, []string) {
@@ -205,6 +305,7 @@ func (c Commands) SetBatchTerminator(terminator string) error {
 		return err
 	}
 	cmd.regex = regex
+	cmd.syntax = terminator + " [count]"
 	return nil
 }
 
@@ -290,17 +391,22 @@ func goCommand(s *Sqlcmd, args []string, line uint) error {
 		return nil
 	}
 	query = s.getRunnableQuery(query)
+	start := time.Now()
 	for i := 0; i < n; i++ {
 		if retcode, err := s.runQuery(query); err != nil {
+			// the statistics cover the executions that ran, including the one that failed
+			s.printPerfStats(i+1, time.Since(start))
 			s.Exitcode = retcode
 			return err
 		}
 	}
+	s.printPerfStats(n, time.Since(start))
 	s.batch.Reset(nil)
 	return nil
 }
 
-// outCommand changes the output writer to use a file
+// outCommand changes the output writer to use a file.
+// A file name with {batch}, {resultset} or {timestamp} placeholders writes each batch or result set to its own file.
 func outCommand(s *Sqlcmd, args []string, line uint) error {
 	if len(args) == 0 || args[0] == "" {
 		return InvalidCommandError("OUT", line)
@@ -315,21 +421,39 @@ func outCommand(s *Sqlcmd, args []string, line uint) error {
 		s.SetOutput(os.Stdout)
 	case strings.EqualFold(filePath, "stderr"):
 		s.SetOutput(os.Stderr)
+	case hasOutputPlaceholder(filePath):
+		// The files are created as output is written, so check the folder now
+		if _, err := os.Stat(filepath.Dir(filePath)); err != nil {
+			return InvalidFileError(err, args[0])
+		}
+		newFormatter, binary := fileFormatters[strings.ToLower(filepath.Ext(filePath))]
+		var enc encoding.Encoding
+		if !binary {
+			enc = s.outputEncoding()
+		}
+		split := newSplitOutput(filePath, enc)
+		if err := split.check(); err != nil {
+			return InvalidFileError(err, args[0])
+		}
+		s.SetOutput(split)
+		if binary {
+			s.setFileFormatter(newFormatter(s.vars))
+			return nil
+		}
 	default:
 		o, err := os.OpenFile(filePath, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0o644)
 		if err != nil {
 			return InvalidFileError(err, args[0])
 		}
-		if s.UnicodeOutputFile {
-			// ODBC sqlcmd doesn't write a BOM but we will.
-			// Maybe the endian-ness should be configurable.
-			win16le := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
-			encoder := transform.NewWriter(o, win16le.NewEncoder())
-			s.SetOutput(encoder)
-		} else {
+		if newFormatter, ok := fileFormatters[strings.ToLower(filepath.Ext(filePath))]; ok {
+			// Binary formats are written as-is, without Unicode encoding
 			s.SetOutput(o)
+			s.setFileFormatter(newFormatter(s.vars))
+			return nil
 		}
+		s.SetOutput(encodeFile(o, s.outputEncoding()))
 	}
+	s.restoreFormatter()
 	return nil
 }
 
@@ -352,11 +476,152 @@ func errorCommand(s *Sqlcmd, args []string, line uint) error {
 		if err != nil {
 			return InvalidFileError(err, args[0])
 		}
-		s.SetError(o)
+		s.SetError(encodeFile(o, s.outputEncoding()))
+	}
+	return nil
+}
+
+// describeCommand prints the metadata of a database object through the formatter
+func describeCommand(s *Sqlcmd, args []string, line uint) error {
+	if len(args) == 0 || strings.TrimSpace(args[0]) == "" {
+		return InvalidCommandError("DESCRIBE", line)
+	}
+	name, err := resolveArgumentVariables(s, []rune(strings.TrimSpace(args[0])), true)
+	if err != nil {
+		return err
+	}
+	o, err := s.findObject(name)
+	if err != nil {
+		return err
+	}
+	_, err = s.runQuery(describeQuery(o))
+	return err
+}
+
+// perfTraceCommand changes the destination of performance statistics
+func perfTraceCommand(s *Sqlcmd, args []string, line uint) error {
+	if len(args) == 0 || args[0] == "" {
+		return InvalidCommandError("PERFTRACE", line)
+	}
+	filePath, err := resolveArgumentVariables(s, []rune(args[0]), true)
+	if err != nil {
+		return err
+	}
+	switch {
+	case strings.EqualFold(filePath, "stderr"):
+		s.SetPerfTrace(os.Stderr)
+	case strings.EqualFold(filePath, "stdout"):
+		s.SetPerfTrace(os.Stdout)
+	default:
+		o, err := os.OpenFile(filePath, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return InvalidFileError(err, args[0])
+		}
+		s.SetPerfTrace(encodeFile(o, s.outputEncoding()))
+	}
+	return nil
+}
+
+// teeCommand copies the output to a file as well as writing it to the current output.
+// :TEE file APPEND adds to the end of an existing file and :TEE OFF stops copying.
+func teeCommand(s *Sqlcmd, args []string, line uint) error {
+	if len(args) == 0 || strings.TrimSpace(args[0]) == "" {
+		return InvalidCommandError("TEE", line)
+	}
+	arg := strings.TrimSpace(args[0])
+	if s.tee != nil {
+		_ = s.tee.Close()
+		s.tee = nil
+	}
+	if strings.EqualFold(arg, "off") {
+		return nil
+	}
+	flags := os.O_TRUNC | os.O_CREATE | os.O_WRONLY
+	if params := strings.Fields(arg); len(params) > 1 && strings.EqualFold(params[len(params)-1], "append") {
+		arg = strings.TrimSpace(arg[:len(arg)-len("append")])
+		flags = os.O_APPEND | os.O_CREATE | os.O_WRONLY
+	}
+	filePath, err := resolveArgumentVariables(s, []rune(arg), true)
+	if err != nil {
+		return err
+	}
+	o, err := os.OpenFile(filePath, flags, 0o644)
+	if err != nil {
+		return InvalidFileError(err, arg)
+	}
+	s.tee = &teeFile{file: encodeFile(o, s.outputEncoding())}
+	return nil
+}
+
+// blobOutCommand writes the values of large columns, such as varbinary(max), to files in a folder
+// instead of printing them. The files are named by the value of the name column, or numbered
+// when it's omitted. Only formatters that implement RowFormatter support it. :BLOBOUT OFF prints
+// the values again.
+func blobOutCommand(s *Sqlcmd, args []string, line uint) error {
+	if len(args) == 0 || strings.TrimSpace(args[0]) == "" {
+		return InvalidCommandError("BLOBOUT", line)
+	}
+	arg, err := resolveArgumentVariables(s, []rune(args[0]), true)
+	if err != nil {
+		return err
+	}
+	params := strings.Fields(arg)
+	switch {
+	case len(params) == 1 && strings.EqualFold(params[0], "off"):
+		s.blobOut = nil
+		return nil
+	case len(params) > 2:
+		return InvalidCommandError("BLOBOUT", line)
+	}
+	if err = os.MkdirAll(params[0], 0o755); err != nil {
+		return InvalidFileError(err, params[0])
+	}
+	s.blobOut = &blobOutput{dir: params[0]}
+	if len(params) == 2 {
+		s.blobOut.nameColumn = params[1]
 	}
 	return nil
 }
 
+// helpCommand prints the syntax and description of every command, or of the named command
+func helpCommand(s *Sqlcmd, args []string, line uint) error {
+	name := ""
+	if len(args) > 0 {
+		name = strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(args[0]), ":"))
+	}
+	commands := make([]*Command, 0, len(s.Cmd))
+	for _, cmd := range s.Cmd {
+		if name == "" || name == commandWord(cmd) || strings.HasPrefix(strings.ToUpper(strings.TrimPrefix(cmd.syntax, ":")), name+" ") {
+			commands = append(commands, cmd)
+		}
+	}
+	if len(commands) == 0 {
+		return InvalidCommandError("HELP", line)
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return strings.TrimPrefix(commands[i].syntax, ":") < strings.TrimPrefix(commands[j].syntax, ":")
+	})
+	b := new(strings.Builder)
+	for _, cmd := range commands {
+		b.WriteString(cmd.syntax + SqlcmdEol)
+		b.WriteString("    " + cmd.help + SqlcmdEol)
+		if cmd.disabled {
+			b.WriteString("    " + localizer.Sprintf("This command is disabled.") + SqlcmdEol)
+		}
+	}
+	_, err := s.GetOutput().Write([]byte(b.String()))
+	return err
+}
+
+// commandWord returns the word that starts the command in its syntax, such as R for :R <filename>
+func commandWord(cmd *Command) string {
+	word := strings.TrimPrefix(cmd.syntax, ":")
+	if i := strings.IndexAny(word, " [(<"); i > 0 {
+		word = word[:i]
+	}
+	return strings.ToUpper(word)
+}
+
 func readFileCommand(s *Sqlcmd, args []string, line uint) error {
 	if args == nil || len(args) != 1 {
 		return InvalidCommandError(":R", line)
@@ -401,7 +666,7 @@ func listVarCommand(s *Sqlcmd, args []string, line uint) error {
 	vars := s.vars.All()
 	keys := make([]string, 0, len(vars))
 	for k := range vars {
-		if !contains(builtinVariables, k) {
+		if !slices.Contains(builtinVariables, k) {
 			keys = append(keys, k)
 		}
 	}
@@ -413,6 +678,25 @@ func listVarCommand(s *Sqlcmd, args []string, line uint) error {
 	return nil
 }
 
+// serverListCommand prints the instances reported by the SQL Server Browser service in the same format as -L
+func serverListCommand(s *Sqlcmd, args []string, line uint) error {
+	if args != nil && strings.TrimSpace(args[0]) != "" {
+		return InvalidCommandError("SERVERLIST", line)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), browserTimeout)
+	defer cancel()
+	data, err := browser.ListInstances(ctx, browserAddress)
+	if err != nil {
+		return err
+	}
+	out := s.GetOutput()
+	fmt.Fprint(out, SqlcmdEol+localizer.Sprintf("Servers:")+SqlcmdEol)
+	for _, name := range browser.ServerNames(data) {
+		fmt.Fprint(out, "   "+name+SqlcmdEol)
+	}
+	return nil
+}
+
 // resetCommand resets the statement cache
 func resetCommand(s *Sqlcmd, args []string, line uint) error {
 	if s.batch != nil {
@@ -584,15 +868,17 @@ func xmlCommand(s *Sqlcmd, args []string, line uint) error {
 	if len(args) != 1 || args[0] == "" {
 		return InvalidCommandError("XML", line)
 	}
-	params := strings.TrimSpace(args[0])
+	params := strings.Fields(args[0])
 	// "OFF" and "ON" are documented as the allowed values.
 	// ODBC sqlcmd treats any value other than "ON" the same as "OFF".
-	// So we will too.
-	if strings.EqualFold(params, "on") {
-		s.Format.XmlMode(true)
-	} else {
-		s.Format.XmlMode(false)
+	// So we will too. "ON INDENT" also reformats the XML with one element per line.
+	on := len(params) > 0 && strings.EqualFold(params[0], "on")
+	indent := on && len(params) == 2 && strings.EqualFold(params[1], "indent")
+	if on && len(params) > 1 && !indent {
+		return InvalidCommandError("XML", line)
 	}
+	s.Format.XmlMode(on)
+	setXmlIndent(s.Format, indent)
 	return nil
 }
 
@@ -6,10 +6,13 @@ package sqlcmd
 import (
 	"bytes"
 	"fmt"
+	"net"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/microsoft/go-mssqldb/msdsn"
 	"github.com/microsoft/go-sqlcmd/internal/color"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -52,6 +55,14 @@ func TestCommandParsing(t *testing.T) {
 		{` !! dir c:\`, "EXEC", []string{` dir c:\`}},
 		{`!!dir c:\`, "EXEC", []string{`dir c:\`}},
 		{`:XML ON `, "XML", []string{`ON `}},
+		{`:TEE session.log APPEND`, "TEE", []string{`session.log APPEND`}},
+		{`:tee off`, "TEE", []string{`off`}},
+		{`:BLOBOUT /tmp/files name`, "BLOBOUT", []string{`/tmp/files name`}},
+		{`:HELP`, "HELP", []string{""}},
+		{`:ServerList`, "SERVERLIST", []string{""}},
+		{`:Perftrace stdout`, "PERFTRACE", []string{"stdout"}},
+		{`:DESCRIBE dbo.orders`, "DESCRIBE", []string{"dbo.orders"}},
+		{`:help setvar`, "HELP", []string{`setvar`}},
 		{`:RESET`, "RESET", []string{""}},
 		{`RESET`, "RESET", []string{""}},
 	}
@@ -161,6 +172,24 @@ func TestVarCommands(t *testing.T) {
 
 }
 
+func TestListVarIncludesDisplayVariables(t *testing.T) {
+	vars := InitializeVariables(false)
+	vars.Set(SQLCMDNULLDISPLAY, "(null)")
+	s := New(nil, "", vars)
+	buf := &memoryBuffer{buf: new(bytes.Buffer)}
+	s.SetOutput(buf)
+	err := listVarCommand(s, []string{""}, 1)
+	assert.NoError(t, err, "listVarCommand")
+	s.SetOutput(nil)
+	output := strings.Split(buf.buf.String(), SqlcmdEol)
+	require.Greater(t, len(output), len(builtinVariables))
+	listed := output[:len(builtinVariables)]
+	assert.Contains(t, listed, `SQLCMDNULLDISPLAY = "(null)"`)
+	assert.Contains(t, listed, `SQLCMDDATETIMEFORMAT = "odbc"`)
+	assert.Contains(t, listed, `SQLCMDNUMBERFORMAT = ""`)
+	assert.Equal(t, `SQLCMDCOLORSCHEME = ""`, listed[17], "the variables of ODBC sqlcmd are listed first")
+}
+
 // memoryBuffer has both Write and Close methods for use as io.WriteCloser
 type memoryBuffer struct {
 	buf *bytes.Buffer
@@ -464,3 +493,99 @@ func TestExitCommandAppendsParameterToCurrentBatch(t *testing.T) {
 	}
 
 }
+
+func TestHelpCommand(t *testing.T) {
+	vars := InitializeVariables(false)
+	s := New(nil, "", vars)
+	buf := &memoryBuffer{buf: new(bytes.Buffer)}
+	s.SetOutput(buf)
+
+	err := helpCommand(s, []string{""}, 1)
+	assert.NoError(t, err, ":HELP")
+	o := buf.buf.String()
+	for _, cmd := range s.Cmd {
+		assert.Contains(t, o, cmd.syntax+SqlcmdEol+"    "+cmd.help+SqlcmdEol, "%s missing from :HELP", cmd.name)
+	}
+	assert.NotContains(t, o, "disabled", "no command is disabled")
+	assert.True(t, strings.HasPrefix(o, ":!! <command>"+SqlcmdEol), ":HELP should sort the commands")
+
+	buf.buf.Reset()
+	err = helpCommand(s, []string{":r"}, 1)
+	assert.NoError(t, err, ":HELP :r")
+	assert.Equal(t, ":R <filename>"+SqlcmdEol+"    "+s.Cmd["READFILE"].help+SqlcmdEol, buf.buf.String())
+
+	buf.buf.Reset()
+	err = helpCommand(s, []string{"go"}, 1)
+	assert.NoError(t, err, ":HELP go")
+	assert.True(t, strings.HasPrefix(buf.buf.String(), "GO [count]"+SqlcmdEol), ":HELP go")
+
+	buf.buf.Reset()
+	err = helpCommand(s, []string{"on error"}, 1)
+	assert.NoError(t, err, ":HELP on error")
+	assert.True(t, strings.HasPrefix(buf.buf.String(), ":ON ERROR"), ":HELP on error")
+
+	err = helpCommand(s, []string{"nosuchcommand"}, 1)
+	assert.EqualError(t, err, InvalidCommandError("HELP", 1).Error(), ":HELP of an unknown command")
+}
+
+func TestHelpCommandShowsDisabledCommandsAndBatchTerminator(t *testing.T) {
+	vars := InitializeVariables(false)
+	s := New(nil, "", vars)
+	buf := &memoryBuffer{buf: new(bytes.Buffer)}
+	s.SetOutput(buf)
+	s.Cmd.DisableSysCommands(false)
+	err := s.Cmd.SetBatchTerminator("END")
+	assert.NoError(t, err, "SetBatchTerminator")
+
+	err = helpCommand(s, []string{"!!"}, 1)
+	assert.NoError(t, err, ":HELP !!")
+	assert.Contains(t, buf.buf.String(), ":!! <command>"+SqlcmdEol+"    "+s.Cmd["EXEC"].help+SqlcmdEol+"    This command is disabled."+SqlcmdEol)
+
+	buf.buf.Reset()
+	err = helpCommand(s, []string{"end"}, 1)
+	assert.NoError(t, err, ":HELP end")
+	assert.True(t, strings.HasPrefix(buf.buf.String(), "END [count]"+SqlcmdEol), ":HELP should show the batch terminator")
+	err = helpCommand(s, []string{"go"}, 1)
+	assert.Error(t, err, "GO is no longer the batch terminator")
+}
+
+func TestServerListCommand(t *testing.T) {
+	// a local UDP responder stands in for the SQL Server Browser service
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err, "ListenPacket")
+	defer conn.Close()
+	instances := "ServerName;HOST1;InstanceName;MSSQLSERVER;tcp;1433;;ServerName;HOST1;InstanceName;SQLEXPRESS;tcp;50123;;"
+	go func() {
+		req := make([]byte, 16)
+		_, addr, err := conn.ReadFrom(req)
+		if err == nil {
+			_, _ = conn.WriteTo(append([]byte{5, byte(len(instances)), 0}, instances...), addr)
+		}
+	}()
+	defer func(address string, timeout time.Duration) {
+		browserAddress, browserTimeout = address, timeout
+	}(browserAddress, browserTimeout)
+	browserAddress, browserTimeout = conn.LocalAddr().String(), 5*time.Second
+
+	vars := InitializeVariables(false)
+	s := New(nil, "", vars)
+	buf := &memoryBuffer{buf: new(bytes.Buffer)}
+	s.SetOutput(buf)
+	err = serverListCommand(s, []string{""}, 1)
+	if assert.NoError(t, err, ":SERVERLIST") {
+		assert.Equal(t, SqlcmdEol+"Servers:"+SqlcmdEol+"   (local)"+SqlcmdEol+"   HOST1"+SqlcmdEol+`   HOST1\SQLEXPRESS`+SqlcmdEol, buf.buf.String())
+	}
+	// the listed names are accepted by :CONNECT
+	for name, host := range map[string]string{"(local)": "localhost", `HOST1\SQLEXPRESS`: "HOST1"} {
+		connectionString, err := ConnectSettings{ServerName: name}.ConnectionString()
+		if assert.NoError(t, err, "ConnectionString for %s", name) {
+			config, err := msdsn.Parse(connectionString)
+			if assert.NoError(t, err, "msdsn.Parse for %s", name) {
+				assert.Equal(t, host, config.Host, "host of %s", name)
+			}
+		}
+	}
+
+	err = serverListCommand(s, []string{"HOST1"}, 1)
+	assert.EqualError(t, err, InvalidCommandError("SERVERLIST", 1).Error(), ":SERVERLIST with an argument")
+}
@@ -0,0 +1,138 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/microsoft/go-sqlcmd/internal/localizer"
+)
+
+// describedObject identifies the object shown by :DESCRIBE
+type describedObject struct {
+	id int
+	// kind is the type column of sys.objects, such as U for a table or P for a procedure
+	kind string
+}
+
+// findObject looks up an object by its name, which may include the schema
+func (s *Sqlcmd) findObject(name string) (*describedObject, error) {
+	o := &describedObject{}
+	row := s.db.QueryRowContext(context.Background(), "SELECT o.object_id, RTRIM(o.type) FROM sys.all_objects o WHERE o.object_id = OBJECT_ID(@p1)", name)
+	if err := row.Scan(&o.id, &o.kind); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &CommonSqlcmdErr{message: ErrorPrefix + localizer.Sprintf("Object '%s' does not exist or you don't have permission to see it.", name)}
+		}
+		return nil, err
+	}
+	return o, nil
+}
+
+// describeQuery returns the batch of metadata queries shown for the object. Sections that
+// don't apply to the kind of object are left out, as are indexes, foreign keys and triggers
+// when there aren't any.
+func describeQuery(o *describedObject) string {
+	hasRows := false
+	queries := []string{}
+	switch o.kind {
+	case "U", "S", "IT", "V":
+		hasRows = true
+		queries = append(queries, describeColumnsQuery, describeIndexesQuery, describeForeignKeysQuery, describeTriggersQuery)
+	case "IF", "TF", "FT":
+		queries = append(queries, describeParametersQuery, describeColumnsQuery)
+	case "P", "PC", "X", "FN", "FS", "AF":
+		queries = append(queries, describeParametersQuery)
+	}
+	rows := ""
+	if hasRows {
+		rows = ", (SELECT SUM(p.rows) FROM sys.partitions p WHERE p.object_id = o.object_id AND p.index_id IN (0, 1)) AS [Rows]"
+	}
+	b := new(strings.Builder)
+	b.WriteString(fmt.Sprintf("DECLARE @object_id int = %d;", o.id) + SqlcmdEol)
+	// row counts would print between the sections, so NOCOUNT is restored after the queries
+	b.WriteString("DECLARE @describe_nocount bit = IIF(@@OPTIONS & 512 = 512, 1, 0);" + SqlcmdEol)
+	b.WriteString("SET NOCOUNT ON;" + SqlcmdEol)
+	b.WriteString(fmt.Sprintf(describeObjectQuery, rows) + SqlcmdEol)
+	for _, q := range queries {
+		b.WriteString(q + SqlcmdEol)
+	}
+	b.WriteString("IF @describe_nocount = 0 SET NOCOUNT OFF;" + SqlcmdEol)
+	return b.String()
+}
+
+// describeTypeName returns the expression of the type of a column or parameter, such as nvarchar(50)
+// or decimal(10,2), for a row of sys.all_columns or sys.all_parameters with the given alias
+func describeTypeName(alias string) string {
+	return strings.ReplaceAll(`TYPE_NAME(c.user_type_id) + CASE
+    WHEN TYPE_NAME(c.user_type_id) IN (N'varchar', N'char', N'varbinary', N'binary') THEN N'(' + IIF(c.max_length = -1, N'max', CAST(c.max_length AS nvarchar(10))) + N')'
+    WHEN TYPE_NAME(c.user_type_id) IN (N'nvarchar', N'nchar') THEN N'(' + IIF(c.max_length = -1, N'max', CAST(c.max_length / 2 AS nvarchar(10))) + N')'
+    WHEN TYPE_NAME(c.user_type_id) IN (N'decimal', N'numeric') THEN N'(' + CAST(c.precision AS nvarchar(10)) + N',' + CAST(c.scale AS nvarchar(10)) + N')'
+    WHEN TYPE_NAME(c.user_type_id) IN (N'datetime2', N'datetimeoffset', N'time') THEN N'(' + CAST(c.scale AS nvarchar(10)) + N')'
+    ELSE N'' END`, "c.", alias+".")
+}
+
+const describeObjectQuery = `SELECT QUOTENAME(SCHEMA_NAME(o.schema_id)) + N'.' + QUOTENAME(o.name) AS [Name], o.type_desc AS [Type]%s, o.create_date AS [Created], o.modify_date AS [Modified]
+FROM sys.all_objects o WHERE o.object_id = @object_id;`
+
+var describeColumnsQuery = `SELECT c.name AS [Column], ` + describeTypeName("c") + ` AS [Type],
+    IIF(c.is_nullable = 1, N'YES', N'NO') AS [Nullable],
+    d.definition AS [Default],
+    IIF(c.is_identity = 1, N'IDENTITY(' + CAST(ic.seed_value AS nvarchar(40)) + N',' + CAST(ic.increment_value AS nvarchar(40)) + N')', NULL) AS [Identity],
+    cc.definition AS [Computed]
+FROM sys.all_columns c
+LEFT JOIN sys.default_constraints d ON d.object_id = c.default_object_id
+LEFT JOIN sys.identity_columns ic ON ic.object_id = c.object_id AND ic.column_id = c.column_id
+LEFT JOIN sys.computed_columns cc ON cc.object_id = c.object_id AND cc.column_id = c.column_id
+WHERE c.object_id = @object_id
+ORDER BY c.column_id;`
+
+// describeList returns the expression that joins the values selected by the query with commas.
+// STRING_AGG would be simpler, but it needs SQL Server 2017.
+func describeList(value string, query string) string {
+	return `STUFF((SELECT N', ' + ` + value + ` ` + query + `
+        FOR XML PATH(N''), TYPE).value(N'.', N'nvarchar(max)'), 1, 2, N'')`
+}
+
+var describeIndexesQuery = `IF EXISTS (SELECT 1 FROM sys.indexes WHERE object_id = @object_id AND type > 0)
+SELECT i.name AS [Index], i.type_desc AS [Type],
+    CASE WHEN i.is_primary_key = 1 THEN N'PRIMARY KEY' WHEN i.is_unique_constraint = 1 THEN N'UNIQUE CONSTRAINT' WHEN i.is_unique = 1 THEN N'UNIQUE' ELSE N'' END AS [Unique],
+    ` + describeList(`COL_NAME(ic.object_id, ic.column_id) + IIF(ic.is_descending_key = 1, N' DESC', N'')`,
+	`FROM sys.index_columns ic WHERE ic.object_id = i.object_id AND ic.index_id = i.index_id AND ic.is_included_column = 0 ORDER BY ic.key_ordinal, ic.index_column_id`) + ` AS [Columns],
+    ` + describeList(`COL_NAME(ic.object_id, ic.column_id)`,
+	`FROM sys.index_columns ic WHERE ic.object_id = i.object_id AND ic.index_id = i.index_id AND ic.is_included_column = 1 ORDER BY ic.index_column_id`) + ` AS [Included],
+    i.filter_definition AS [Filter]
+FROM sys.indexes i
+WHERE i.object_id = @object_id AND i.type > 0
+ORDER BY i.index_id;`
+
+var describeForeignKeysQuery = `IF EXISTS (SELECT 1 FROM sys.foreign_keys WHERE parent_object_id = @object_id)
+SELECT fk.name AS [Foreign Key],
+    ` + describeList(`COL_NAME(fkc.parent_object_id, fkc.parent_column_id)`,
+	`FROM sys.foreign_key_columns fkc WHERE fkc.constraint_object_id = fk.object_id ORDER BY fkc.constraint_column_id`) + ` AS [Columns],
+    QUOTENAME(OBJECT_SCHEMA_NAME(fk.referenced_object_id)) + N'.' + QUOTENAME(OBJECT_NAME(fk.referenced_object_id)) AS [References],
+    ` + describeList(`COL_NAME(fkc.referenced_object_id, fkc.referenced_column_id)`,
+	`FROM sys.foreign_key_columns fkc WHERE fkc.constraint_object_id = fk.object_id ORDER BY fkc.constraint_column_id`) + ` AS [Referenced Columns],
+    fk.delete_referential_action_desc AS [On Delete],
+    fk.update_referential_action_desc AS [On Update]
+FROM sys.foreign_keys fk
+WHERE fk.parent_object_id = @object_id
+ORDER BY fk.name;`
+
+var describeTriggersQuery = `IF EXISTS (SELECT 1 FROM sys.triggers WHERE parent_id = @object_id)
+SELECT t.name AS [Trigger], IIF(t.is_instead_of_trigger = 1, N'INSTEAD OF', N'AFTER') AS [Type],
+    ` + describeList(`te.type_desc`, `FROM sys.trigger_events te WHERE te.object_id = t.object_id ORDER BY te.type`) + ` AS [Events],
+    IIF(t.is_disabled = 1, N'NO', N'YES') AS [Enabled]
+FROM sys.triggers t
+WHERE t.parent_id = @object_id
+ORDER BY t.name;`
+
+var describeParametersQuery = `SELECT IIF(p.parameter_id = 0, N'(return value)', p.name) AS [Parameter], ` + describeTypeName("p") + ` AS [Type],
+    CASE WHEN p.parameter_id = 0 THEN N'RETURN' WHEN p.is_output = 1 THEN N'OUTPUT' ELSE N'INPUT' END AS [Direction],
+    IIF(p.is_readonly = 1, N'YES', N'NO') AS [Read Only]
+FROM sys.all_parameters p
+WHERE p.object_id = @object_id
+ORDER BY p.parameter_id;`
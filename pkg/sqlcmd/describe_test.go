@@ -0,0 +1,57 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeQuery(t *testing.T) {
+	table := describeQuery(&describedObject{id: 1234, kind: "U"})
+	assert.True(t, strings.HasPrefix(table, "DECLARE @object_id int = 1234;"+SqlcmdEol), "object id of the table")
+	for _, q := range []string{describeColumnsQuery, describeIndexesQuery, describeForeignKeysQuery, describeTriggersQuery} {
+		assert.Contains(t, table, q, "table sections")
+	}
+	assert.Contains(t, table, "AS [Rows]", "row count of the table")
+	assert.NotContains(t, table, describeParametersQuery, "tables have no parameters")
+
+	procedure := describeQuery(&describedObject{id: 5678, kind: "P"})
+	assert.Contains(t, procedure, describeParametersQuery, "procedure parameters")
+	assert.NotContains(t, procedure, describeColumnsQuery, "procedures have no columns")
+	assert.NotContains(t, procedure, "AS [Rows]", "procedures have no rows")
+
+	function := describeQuery(&describedObject{id: 5678, kind: "TF"})
+	assert.Contains(t, function, describeParametersQuery, "function parameters")
+	assert.Contains(t, function, describeColumnsQuery, "columns of a table-valued function")
+
+	assert.Contains(t, describeParametersQuery, "TYPE_NAME(p.user_type_id)", "parameter types")
+	assert.NotContains(t, describeParametersQuery, "c.", "parameter types use the parameter alias")
+	assert.NotContains(t, table, "STRING_AGG", "lists of columns don't need SQL Server 2017")
+}
+
+func TestDescribeCommand(t *testing.T) {
+	s, buf := setupSqlCmdWithMemoryOutput(t)
+	defer buf.Close()
+	err := describeCommand(s, []string{""}, 1)
+	assert.EqualError(t, err, InvalidCommandError("DESCRIBE", 1).Error(), ":DESCRIBE without an object")
+
+	err = runSqlCmd(t, s, []string{":DESCRIBE sys.objects"})
+	require.NoError(t, err, ":DESCRIBE sys.objects")
+	o := buf.buf.String()
+	assert.Contains(t, o, "[sys].[objects]", "object name")
+	assert.Regexp(t, `object_id\s+int\s+NO`, o, "column with its type and nullability")
+	assert.NotContains(t, o, "rows affected", "row counts of the metadata queries")
+
+	buf.buf.Reset()
+	err = runSqlCmd(t, s, []string{":DESCRIBE sp_executesql"})
+	require.NoError(t, err, ":DESCRIBE sp_executesql")
+	assert.Contains(t, buf.buf.String(), "@stmt", "procedure parameters")
+
+	_, err = s.findObject("no_such_object")
+	assert.EqualError(t, err, ErrorPrefix+"Object 'no_such_object' does not exist or you don't have permission to see it.")
+}
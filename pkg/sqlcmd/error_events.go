@@ -0,0 +1,127 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/microsoft/go-sqlcmd/internal/localizer"
+)
+
+// ErrorsFormat selects how errors and messages are reported
+type ErrorsFormat int
+
+const (
+	// ErrorsFormatText prints errors and messages as text through the Formatter
+	ErrorsFormatText ErrorsFormat = iota
+	// ErrorsFormatJSON writes each error and message to stderr as a JSON object on its own line
+	ErrorsFormatJSON
+)
+
+// Kinds of events written by ErrorsFormatJSON
+const (
+	errorEventKind   = "error"
+	messageEventKind = "message"
+)
+
+// errorEvent is the JSON object written for an error or message
+type errorEvent struct {
+	Type           string `json:"type"`
+	Number         int32  `json:"number"`
+	Severity       uint8  `json:"severity"`
+	State          uint8  `json:"state"`
+	Server         string `json:"server"`
+	Procedure      string `json:"procedure"`
+	Line           int32  `json:"line"`
+	Message        string `json:"message"`
+	ScriptFile     string `json:"scriptFile"`
+	BatchStartLine uint   `json:"batchStartLine"`
+	Timestamp      string `json:"timestamp"`
+}
+
+// textMessage is a message generated by sqlcmd, such as a row count
+type textMessage string
+
+func (m textMessage) String() string {
+	return string(m)
+}
+
+// newErrorEvent describes msg, which is an error or a message from the server
+func (s *Sqlcmd) newErrorEvent(kind string, msg interface{}) errorEvent {
+	e := errorEvent{
+		Type:           kind,
+		ScriptFile:     s.scriptFile,
+		BatchStartLine: s.batchStartLine(),
+		Timestamp:      time.Now().Format(time.RFC3339Nano),
+	}
+	switch m := msg.(type) {
+	case mssql.Error:
+		e.Number, e.Severity, e.State = m.Number, m.Class, m.State
+		e.Server, e.Procedure, e.Line = m.ServerName, m.ProcName, m.LineNo
+		e.Message = m.Message
+	case error:
+		if errors.Is(m, context.DeadlineExceeded) {
+			e.Message = localizer.Sprintf("Timeout expired")
+		} else {
+			e.Message = m.Error()
+		}
+	case fmt.Stringer:
+		e.Message = m.String()
+	}
+	return e
+}
+
+// writeErrorEvent writes one event per line to stderr
+func (s *Sqlcmd) writeErrorEvent(kind string, msg interface{}) {
+	b := new(bytes.Buffer)
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	if enc.Encode(s.newErrorEvent(kind, msg)) != nil {
+		return
+	}
+	w := s.eventOutput
+	if w == nil {
+		w = os.Stderr
+	}
+	_, _ = w.Write(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+	_, _ = io.WriteString(w, SqlcmdEol)
+}
+
+// batchStartLine returns the line of the current input file or session where the current batch starts
+func (s *Sqlcmd) batchStartLine() uint {
+	if s.batch.startline < s.lineOffset {
+		return 0
+	}
+	return s.batch.startline - s.lineOffset
+}
+
+// addMessage reports a message from the server or a row count
+func (s *Sqlcmd) addMessage(msg fmt.Stringer) {
+	if s.ErrorsFormat == ErrorsFormatJSON {
+		s.writeErrorEvent(messageEventKind, msg)
+		return
+	}
+	s.Format.AddMessage(msg.String())
+}
+
+// addError reports an error of a query. Errors with a severity below ERRORLEVEL aren't reported.
+func (s *Sqlcmd) addError(err error) {
+	if s.ErrorsFormat != ErrorsFormatJSON {
+		s.Format.AddError(err)
+		return
+	}
+	var e mssql.Error
+	if errors.As(err, &e) && s.vars.ErrorLevel() > 0 && e.Class < uint8(s.vars.ErrorLevel()) {
+		return
+	}
+	s.writeErrorEvent(errorEventKind, err)
+}
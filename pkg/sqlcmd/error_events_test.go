@@ -0,0 +1,96 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func errorEventsTestSqlcmd(t *testing.T) (*Sqlcmd, *bytes.Buffer, *strings.Builder) {
+	t.Helper()
+	vars := InitializeVariables(false)
+	s := New(nil, "", vars)
+	s.Format = NewSQLCmdDefaultFormatter(vars, false, ControlIgnore)
+	out := new(strings.Builder)
+	s.Format.BeginBatch("", vars, out, out)
+	events := new(bytes.Buffer)
+	s.eventOutput = events
+	s.ErrorsFormat = ErrorsFormatJSON
+	return s, events, out
+}
+
+func readErrorEvents(t *testing.T, b *bytes.Buffer) []errorEvent {
+	t.Helper()
+	var events []errorEvent
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), SqlcmdEol), SqlcmdEol) {
+		var e errorEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &e), line)
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestErrorEventsJSON(t *testing.T) {
+	s, b, out := errorEventsTestSqlcmd(t)
+	s.addError(mssql.Error{Number: 208, Class: 16, State: 1, ServerName: "srv", ProcName: "proc", LineNo: 4, Message: "Invalid object name 'x'."})
+	s.addMessage(mssql.Error{Number: 0, Class: 0, State: 1, ServerName: "srv", LineNo: 2, Message: "<printed> & done"})
+	s.addMessage(textMessage("(1 row affected)"))
+	s.WriteError(os.Stdout, errors.New("Sqlcmd: Error: Syntax error at line 3"))
+	s.vars.Set(SQLCMDERRORLEVEL, "11")
+	s.addError(mssql.Error{Number: 50000, Class: 10, State: 1, Message: "below the error level"})
+
+	assert.Empty(t, out.String(), "the formatter doesn't print errors and messages")
+	events := readErrorEvents(t, b)
+	require.Len(t, events, 4)
+	e := events[0]
+	assert.Equal(t, errorEventKind, e.Type)
+	assert.Equal(t, []interface{}{int32(208), uint8(16), uint8(1), "srv", "proc", int32(4), "Invalid object name 'x'."},
+		[]interface{}{e.Number, e.Severity, e.State, e.Server, e.Procedure, e.Line, e.Message})
+	_, err := time.Parse(time.RFC3339Nano, e.Timestamp)
+	assert.NoError(t, err, "timestamp")
+	assert.Equal(t, messageEventKind, events[1].Type)
+	assert.Equal(t, "<printed> & done", events[1].Message)
+	assert.Contains(t, b.String(), `"<printed> & done"`, "HTML characters aren't escaped")
+	assert.Equal(t, "(1 row affected)", events[2].Message)
+	assert.Equal(t, errorEventKind, events[3].Type)
+	assert.Equal(t, "Sqlcmd: Error: Syntax error at line 3", events[3].Message)
+}
+
+func TestErrorEventsText(t *testing.T) {
+	s, b, out := errorEventsTestSqlcmd(t)
+	s.ErrorsFormat = ErrorsFormatText
+	s.addError(mssql.Error{Number: 208, Class: 16, State: 1, ServerName: "srv", LineNo: 4, Message: "Invalid object name 'x'."})
+	s.addMessage(textMessage("(1 row affected)"))
+	assert.Empty(t, b.String())
+	assert.Equal(t, "Msg 208, Level 16, State 1, Server srv, Line 4"+SqlcmdEol+"Invalid object name 'x'."+SqlcmdEol+"(1 row affected)"+SqlcmdEol, out.String())
+}
+
+func TestErrorEventsScriptFileAndBatchLine(t *testing.T) {
+	s, b, _ := errorEventsTestSqlcmd(t)
+	file := filepath.Join(t.TempDir(), "script.sql")
+	require.NoError(t, os.WriteFile(file, []byte(":setvar a 1\nselect 1,\n$(undefined\n"), 0o644))
+	// read a line first so the line numbers are relative to the file
+	s.batch.read = func() (string, error) { return "select 0", nil }
+	_, _, err := s.batch.Next()
+	require.NoError(t, err)
+	s.batch.Reset(nil)
+
+	assert.NoError(t, s.IncludeFile(file, false))
+	events := readErrorEvents(t, b)
+	require.Len(t, events, 1)
+	assert.Equal(t, file, events[0].ScriptFile)
+	assert.Equal(t, uint(2), events[0].BatchStartLine, "the batch starts at the second line of the file")
+	assert.Equal(t, "", s.scriptFile, "the script file is restored when the file ends")
+}
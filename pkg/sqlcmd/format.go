@@ -9,13 +9,20 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	mssql "github.com/microsoft/go-mssqldb"
 	"github.com/microsoft/go-sqlcmd/internal/color"
 	"github.com/microsoft/go-sqlcmd/internal/localizer"
+	"github.com/rivo/uniseg"
 )
 
 const (
@@ -33,8 +40,6 @@ type Formatter interface {
 	BeginResultSet([]*sql.ColumnType)
 	// EndResultSet is called after all rows in a result set have been processed
 	EndResultSet()
-	// AddRow is called for each row in a result set. It returns the value of the first column
-	AddRow(*sql.Rows) string
 	// AddMessage is called for every information message returned by the server during the batch
 	AddMessage(string)
 	// AddError is called for each error encountered during batch execution
@@ -45,6 +50,50 @@ type Formatter interface {
 	IsXmlMode() bool
 }
 
+// xmlIndentFormatter is implemented by formatters that can reformat XML mode output with one
+// element per line for :XML ON INDENT. Other formatters print XML mode output unchanged.
+type xmlIndentFormatter interface {
+	// XmlIndent enables or disables reformatting XML mode output with one element per line
+	XmlIndent(enable bool)
+	// IsXmlIndent returns whether XML mode output is indented
+	IsXmlIndent() bool
+}
+
+// setXmlIndent enables or disables indenting XML mode output if the formatter supports it
+func setXmlIndent(f Formatter, enable bool) {
+	if x, ok := f.(xmlIndentFormatter); ok {
+		x.XmlIndent(enable)
+	}
+}
+
+// isXmlIndent returns whether the formatter indents XML mode output
+func isXmlIndent(f Formatter) bool {
+	x, ok := f.(xmlIndentFormatter)
+	return ok && x.IsXmlIndent()
+}
+
+// RowScanner is a Formatter that reads the rows of each result set itself. Sqlcmd calls AddRow
+// for formatters that aren't a RowFormatter.
+type RowScanner interface {
+	Formatter
+	// AddRow is called for each row in a result set. It returns the value of the first column.
+	AddRow(*sql.Rows) string
+}
+
+// RowFormatter is a Formatter that receives the values of each row after Sqlcmd scans them,
+// so a result set is read without every formatter scanning it again. Sqlcmd calls BeginColumns
+// instead of BeginResultSet and AddValues instead of AddRow.
+type RowFormatter interface {
+	Formatter
+	// BeginColumns is called when a new result set is encountered with the details of its columns,
+	// which Sqlcmd computes once for the result set
+	BeginColumns(columns []Column)
+	// AddValues is called for each row in a result set with the value of each column as provided
+	// by the driver, such as int64, float64, string, []byte, time.Time, or nil for NULL. The slice
+	// is reused for every row of the result set, so AddValues must not keep it.
+	AddValues(values []interface{})
+}
+
 // ControlCharacterBehavior specifies the text handling required for control characters in the output
 type ControlCharacterBehavior int
 
@@ -68,6 +117,53 @@ type columnDetail struct {
 	scale              int
 }
 
+// Column describes a column of a result set and how sqlcmd displays its values
+type Column struct {
+	detail columnDetail
+}
+
+// Type returns the type of the column reported by the driver
+func (c Column) Type() *sql.ColumnType {
+	return &c.detail.col
+}
+
+// DisplayWidth returns the number of characters the text formats use for values of the column,
+// as limited by SQLCMDMAXFIXEDTYPEWIDTH and SQLCMDMAXVARTYPEWIDTH. It's 0 when values aren't padded.
+func (c Column) DisplayWidth() int64 {
+	return c.detail.displayWidth
+}
+
+// LeftJustify returns true if values of the column are aligned to the left, such as strings
+func (c Column) LeftJustify() bool {
+	return c.detail.leftJustify
+}
+
+// DecimalSize returns the precision and scale of decimal, numeric and time columns
+func (c Column) DecimalSize() (precision int, scale int) {
+	return c.detail.precision, c.detail.scale
+}
+
+// newColumns computes the details of the columns of a result set with the column widths set by vars
+func newColumns(cols []*sql.ColumnType, vars *Variables) []Column {
+	details, _ := calcColumnDetails(cols, vars.MaxFixedColumnWidth(), vars.MaxVarColumnWidth())
+	columns := make([]Column, len(details))
+	for i := range details {
+		columns[i].detail = details[i]
+	}
+	return columns
+}
+
+// columnDetailsOf returns a copy of the details of the columns and the length of the longest column name
+func columnDetailsOf(columns []Column) ([]columnDetail, int) {
+	details := make([]columnDetail, len(columns))
+	maxNameLen := 0
+	for i, c := range columns {
+		details[i] = c.detail
+		maxNameLen = max(maxNameLen, stringWidth(c.detail.col.Name()))
+	}
+	return details, maxNameLen
+}
+
 // The default formatter based on the native sqlcmd style
 // It supports both horizontal (default) and vertical layout for results.
 // Both vertical and horizontal layouts respect column widths set by SQLCMD variables.
@@ -85,6 +181,8 @@ type sqlCmdFormatterType struct {
 	maxColNameLen        int
 	colorizer            color.Colorizer
 	xml                  bool
+	xmlIndent            bool
+	xmlIndenter          xmlIndenter
 	rawErrors            bool
 }
 
@@ -97,13 +195,47 @@ func WithRawErrors(raw bool) FormatterOption {
 	return func(f *sqlCmdFormatterType) { f.rawErrors = raw }
 }
 
-// NewSQLCmdDefaultFormatter returns an ASCII formatter when SQLCMDFORMAT is "ascii",
-// otherwise a formatter that mimics the original ODBC-based sqlcmd formatter.
+// NewSQLCmdDefaultFormatter returns the formatter selected by SQLCMDFORMAT. The values
+// "ascii", "json", "csv", "markdown", "html", "insert", "parquet" and "xlsx" select the formatter of the same name,
+// as do the names of formatters added by RegisterFormatter. Otherwise it returns a formatter that mimics the
+// original ODBC-based sqlcmd formatter. The options only apply to the formatters of this package.
 func NewSQLCmdDefaultFormatter(vars *Variables, removeTrailingSpaces bool, ccb ControlCharacterBehavior, opts ...FormatterOption) Formatter {
-	if vars.Format() == "ascii" {
+	switch vars.Format() {
+	case "ascii":
 		f := NewSQLCmdAsciiFormatter(vars, removeTrailingSpaces, ccb).(*asciiFormatter)
 		applyFormatterOptions(f.sqlCmdFormatterType, opts)
 		return f
+	case "json":
+		f := NewSQLCmdJsonFormatter(vars).(*jsonFormatter)
+		applyFormatterOptions(f.sqlCmdFormatterType, opts)
+		return f
+	case "csv":
+		f := NewSQLCmdCsvFormatter(vars, ccb).(*csvFormatter)
+		applyFormatterOptions(f.sqlCmdFormatterType, opts)
+		return f
+	case "markdown":
+		f := NewSQLCmdMarkdownFormatter(vars, ccb).(*markdownFormatter)
+		applyFormatterOptions(f.sqlCmdFormatterType, opts)
+		return f
+	case "html":
+		f := NewSQLCmdHtmlFormatter(vars, ccb).(*htmlFormatter)
+		applyFormatterOptions(f.sqlCmdFormatterType, opts)
+		return f
+	case "insert":
+		f := NewSQLCmdInsertFormatter(vars).(*insertFormatter)
+		applyFormatterOptions(f.sqlCmdFormatterType, opts)
+		return f
+	case "parquet":
+		f := NewSQLCmdParquetFormatter(vars).(*parquetFormatter)
+		applyFormatterOptions(f.sqlCmdFormatterType, opts)
+		return f
+	case "xlsx":
+		f := NewSQLCmdXlsxFormatter(vars).(*xlsxFormatter)
+		applyFormatterOptions(f.sqlCmdFormatterType, opts)
+		return f
+	}
+	if newFormatter := registeredFormatter(vars.Format()); newFormatter != nil {
+		return newFormatter(vars)
 	}
 	f := &sqlCmdFormatterType{
 		removeTrailingSpaces: removeTrailingSpaces,
@@ -115,6 +247,103 @@ func NewSQLCmdDefaultFormatter(vars *Variables, removeTrailingSpaces bool, ccb C
 	return f
 }
 
+// fileFormatters create the formatter used when :OUT names a file with a binary format
+var fileFormatters = map[string]func(vars *Variables) Formatter{
+	".parquet": NewSQLCmdParquetFormatter,
+	".xlsx":    NewSQLCmdXlsxFormatter,
+}
+
+// builtinFormats are the SQLCMDFORMAT values that select the formatters of this package
+var builtinFormats = []string{"vert", "vertical", "horiz", "horizontal", "ascii", "json", "csv", "md", "markdown", "html", "insert", "parquet", "xlsx"}
+
+var (
+	formattersMu sync.RWMutex
+	// registeredFormatters create the formatters added by RegisterFormatter, by lowercase name
+	registeredFormatters = make(map[string]func(vars *Variables) Formatter)
+)
+
+// RegisterFormatter makes a formatter available as a value of SQLCMDFORMAT, so applications can
+// write results to their own sinks. Names are case insensitive. A formatter that implements
+// RowFormatter receives the rows already scanned by Sqlcmd, like the formatters of this package.
+// Other formatters must implement RowScanner to read the rows themselves.
+// RegisterFormatter panics if it's called twice with the same name, if the name is used by a
+// formatter of this package, or if newFormatter is nil.
+func RegisterFormatter(name string, newFormatter func(vars *Variables) Formatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	name = strings.ToLower(name)
+	if newFormatter == nil {
+		panic("sqlcmd: RegisterFormatter formatter is nil")
+	}
+	if name == "" || slices.Contains(builtinFormats, name) {
+		panic("sqlcmd: RegisterFormatter can't replace the built-in formatter " + strconv.Quote(name))
+	}
+	if _, dup := registeredFormatters[name]; dup {
+		panic("sqlcmd: RegisterFormatter called twice for formatter " + name)
+	}
+	registeredFormatters[name] = newFormatter
+}
+
+// registeredFormatter returns the function that creates the formatter registered with the name, or nil
+func registeredFormatter(name string) func(vars *Variables) Formatter {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	return registeredFormatters[strings.ToLower(name)]
+}
+
+// RegisteredFormatters returns the sorted names of the formatters added by RegisterFormatter
+func RegisteredFormatters() []string {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	names := make([]string, 0, len(registeredFormatters))
+	for name := range registeredFormatters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// numberedFileName returns the name of the nth file written by a formatter that can't append
+// to its output, derived from the name of the output file. The first file is the output itself.
+func numberedFileName(out io.Writer, n int) (string, error) {
+	named, ok := out.(interface{ Name() string })
+	if !ok || consoleFile(out) != nil {
+		return "", localizer.Errorf("Output %d was not written. Writing more than one file requires an output file name", n)
+	}
+	name := named.Name()
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), n, ext), nil
+}
+
+// requireOutputFile returns an error when a formatter with binary output would write to the console
+func requireOutputFile(out io.Writer, format string) error {
+	if consoleFile(out) != nil {
+		return localizer.Errorf("Results were not written. The %s format requires an output file name. Use :OUT or -o to set one", format)
+	}
+	return nil
+}
+
+// outputFile identifies the file behind an output writer. A split output keeps the same
+// writer while moving to new files, so formatters that track their output compare both.
+type outputFile struct {
+	w    io.Writer
+	name string
+}
+
+func currentOutputFile(w io.Writer) outputFile {
+	o := outputFile{w: w}
+	if named, ok := w.(interface{ Name() string }); ok {
+		o.name = named.Name()
+	}
+	return o
+}
+
+// splitsResultSets returns true if the output writes every result set to its own file
+func splitsResultSets(w io.Writer) bool {
+	o, ok := w.(*splitOutput)
+	return ok && o.splitsResultSets()
+}
+
 func applyFormatterOptions(f *sqlCmdFormatterType, opts []FormatterOption) {
 	for _, opt := range opts {
 		if opt != nil {
@@ -131,27 +360,26 @@ func (f *sqlCmdFormatterType) writeOut(s string, t color.TextType) {
 		return
 	}
 
-	r := []rune(s)
-	for i := 0; true; {
-		if i == len(r) {
-			f.mustWriteOut(string(r), t)
-			return
-		} else if f.writepos == w {
-			f.mustWriteOut(string(r[:i]), t)
+	start := 0
+	state := -1
+	for pos := 0; pos < len(s); {
+		cluster, _, cw, st := uniseg.FirstGraphemeClusterInString(s[pos:], state)
+		state = st
+		// A full line wraps before any character, including a line break
+		if f.writepos == w || (f.writepos > 0 && f.writepos+int64(cw) > w) {
+			f.mustWriteOut(s[start:pos], t)
 			f.mustWriteOut(SqlcmdEol, color.TextTypeNormal)
-			r = []rune(string(r[i:]))
+			start = pos
+			f.writepos = 0
+		}
+		if cluster[0] == '\r' || cluster[0] == '\n' {
 			f.writepos = 0
-			i = 0
 		} else {
-			c := r[i]
-			if c != '\r' && c != '\n' {
-				f.writepos++
-			} else {
-				f.writepos = 0
-			}
-			i++
+			f.writepos += int64(cw)
 		}
+		pos += len(cluster)
 	}
+	f.mustWriteOut(s[start:], t)
 }
 
 // BeginBatch stores the settings to use for processing the current batch
@@ -171,8 +399,14 @@ func (f *sqlCmdFormatterType) EndBatch() {
 // Since sql.ColumnType only provides sizes for variable length types we will
 // base our numbers for most types on https://docs.microsoft.com/sql/odbc/reference/appendixes/column-size
 func (f *sqlCmdFormatterType) BeginResultSet(cols []*sql.ColumnType) {
+	f.BeginColumns(newColumns(cols, f.vars))
+}
+
+// BeginColumns prints the names of the columns, whose widths were computed by sqlcmd
+func (f *sqlCmdFormatterType) BeginColumns(columns []Column) {
 	f.rowcount = 0
-	f.columnDetails, f.maxColNameLen = calcColumnDetails(cols, f.vars.MaxFixedColumnWidth(), f.vars.MaxVarColumnWidth())
+	f.columnDetails, f.maxColNameLen = columnDetailsOf(columns)
+	f.widenFormattedNumbers()
 	if f.vars.RowsBetweenHeaders() > -1 && f.format == "horizontal" && !f.xml {
 		f.printColumnHeadings()
 	}
@@ -182,20 +416,29 @@ func (f *sqlCmdFormatterType) BeginResultSet(cols []*sql.ColumnType) {
 func (f *sqlCmdFormatterType) EndResultSet() {
 	if !f.xml {
 		f.writeOut(SqlcmdEol, color.TextTypeNormal)
+	} else if f.xmlIndent {
+		if rest := f.xmlIndenter.flush(); rest != "" {
+			f.writeOut(rest, color.TextTypeXml)
+			f.writeOut(SqlcmdEol, color.TextTypeNormal)
+		}
 	}
 }
 
 // AddRow writes the current row to the designated output writer
 func (f *sqlCmdFormatterType) AddRow(row *sql.Rows) string {
-	retval := ""
-	values, err := f.scanRow(row)
-	if err != nil {
-		f.mustWriteErr(err.Error())
-		return retval
+	return f.addRow(row, f)
+}
+
+// AddValues writes a row to the designated output writer
+func (f *sqlCmdFormatterType) AddValues(row []interface{}) {
+	values := f.displayRow(row)
+	if f.xml && f.xmlIndent {
+		// the rows of FOR XML results are pieces of one document, so only complete lines are written
+		f.writeOut(f.xmlIndenter.write(values[0]), color.TextTypeXml)
+		return
 	}
-	retval = values[0]
 	if f.xml {
-		f.printColumnValue(retval, 0)
+		f.printColumnValue(values[0], 0)
 	} else if f.format == "horizontal" {
 		// values are the full values, look at the displaywidth of each column and truncate accordingly
 		for i, v := range values {
@@ -214,7 +457,6 @@ func (f *sqlCmdFormatterType) AddRow(row *sql.Rows) string {
 		f.addVerticalRow(values)
 	}
 	f.writeOut(SqlcmdEol, color.TextTypeNormal)
-	return retval
 }
 
 func (f *sqlCmdFormatterType) addVerticalRow(values []string) {
@@ -223,7 +465,7 @@ func (f *sqlCmdFormatterType) addVerticalRow(values []string) {
 			builder := new(strings.Builder)
 			name := f.columnDetails[i].col.Name()
 			builder.WriteString(name)
-			builder = padRight(builder, int64(f.maxColNameLen-len(name)+1), " ")
+			builder = padRight(builder, int64(f.maxColNameLen-stringWidth(name)+1), " ")
 			f.writeOut(builder.String(), color.TextTypeHeader)
 		}
 		f.printColumnValue(v, i)
@@ -240,6 +482,14 @@ func (f *sqlCmdFormatterType) AddMessage(msg string) {
 
 // AddError writes an error to the designated err Writer
 func (f *sqlCmdFormatterType) AddError(err error) {
+	if b, ok := f.errorText(err); ok {
+		f.mustWriteErr(fitToScreen(b, f.vars.ScreenWidth()).String())
+	}
+}
+
+// errorText builds the classic sqlcmd text for err. It returns false when
+// the error severity is below the ERRORLEVEL threshold and shouldn't be printed.
+func (f *sqlCmdFormatterType) errorText(err error) (*strings.Builder, bool) {
 	print := true
 	b := new(strings.Builder)
 	if errors.Is(err, context.DeadlineExceeded) {
@@ -262,8 +512,8 @@ func (f *sqlCmdFormatterType) AddError(err error) {
 	if print {
 		b.WriteString(msg)
 		b.WriteString(SqlcmdEol)
-		f.mustWriteErr(fitToScreen(b, f.vars.ScreenWidth()).String())
 	}
+	return b, print
 }
 
 // XmlMode enables or disables XML mode
@@ -276,15 +526,30 @@ func (f *sqlCmdFormatterType) IsXmlMode() bool {
 	return f.xml
 }
 
+// XmlIndent enables or disables indenting XML mode output
+func (f *sqlCmdFormatterType) XmlIndent(enable bool) {
+	f.xmlIndent = enable
+}
+
+// IsXmlIndent returns whether XML mode output is indented
+func (f *sqlCmdFormatterType) IsXmlIndent() bool {
+	return f.xmlIndent
+}
+
 // Prints column headings based on columnDetail, variables, and command line arguments
 func (f *sqlCmdFormatterType) printColumnHeadings() {
+	if p, ok := f.out.(*pagerBuffer); ok {
+		// The pager keeps the headings on screen while the rows scroll
+		p.beginHeader()
+		defer p.endHeader()
+	}
 	names := new(strings.Builder)
 	sep := new(strings.Builder)
 
 	var leftPad, rightPad int64
 	for i, c := range f.columnDetails {
 		rightPad = 0
-		nameLen := int64(len([]rune(c.col.Name())))
+		nameLen := int64(stringWidth(c.col.Name()))
 		if f.removeTrailingSpaces {
 			if nameLen == 0 {
 				// special case for unnamed columns when using -W
@@ -302,7 +567,7 @@ func (f *sqlCmdFormatterType) printColumnHeadings() {
 			sep = padRight(sep, length, "-")
 		}
 		names = padRight(names, leftPad, " ")
-		names.WriteString(c.col.Name()[:min64(nameLen, c.displayWidth)])
+		names.WriteString(truncateWidth(c.col.Name(), int(min64(nameLen, c.displayWidth))))
 		names = padRight(names, rightPad, " ")
 		if i != len(f.columnDetails)-1 {
 			names.WriteString(f.colsep)
@@ -322,32 +587,39 @@ func (f *sqlCmdFormatterType) printColumnHeadings() {
 // When width > 0 returns a new Builder containing the wrapped string
 func fitToScreen(s *strings.Builder, width int64) *strings.Builder {
 	str := s.String()
-	runes := []rune(str)
-	if width == 0 || int64(len(runes)) < width {
+	if width == 0 || int64(stringWidth(str)) < width {
 		return s
 	}
 
 	line := new(strings.Builder)
 	line.Grow(len(str))
 	var c int64
-	for i, r := range runes {
-		if c == width {
-			// We have printed a line's worth
-			// if the next character is not part of a carriage return write our Eol
-			if (SqlcmdEol == "\r\n" && (i == len(runes)-1 || (i < len(runes)-1 && string(runes[i:i+2]) != SqlcmdEol))) || (SqlcmdEol == "\n" && r != '\n') {
-				line.WriteString(SqlcmdEol)
-				c = 0
-			}
+	state := -1
+	for str != "" {
+		var cluster string
+		var cw int
+		cluster, str, cw, state = uniseg.FirstGraphemeClusterInString(str, state)
+		if cluster == "\n" || cluster == "\r\n" {
+			line.WriteString(cluster)
+			c = 0
+			continue
 		}
-		line.WriteRune(r)
-		if r == '\n' {
+		if c > 0 && (c == width || c+int64(cw) > width) {
+			// We have printed a line's worth
+			line.WriteString(SqlcmdEol)
 			c = 0
-			// we are assuming \r is a non-printed character
+		}
+		line.WriteString(cluster)
+		if cluster == "\r" {
+			// we are assuming \r is a non-printed character on Windows
 			// The likelihood of a \r not being followed by \n is low
-		} else if r == '\r' && SqlcmdEol == "\r\n" {
-			c = 0
+			if SqlcmdEol == "\r\n" {
+				c = 0
+			} else {
+				c++
+			}
 		} else {
-			c++
+			c += int64(cw)
 		}
 	}
 	return line
@@ -361,7 +633,7 @@ func calcColumnDetails(cols []*sql.ColumnType, fixed int64, variable int64) ([]c
 	maxNameLen := 0
 	for i, c := range cols {
 		length, _ := c.Length()
-		nameLen := int64(len([]rune(c.Name())))
+		nameLen := int64(stringWidth(c.Name()))
 		if nameLen > int64(maxNameLen) {
 			maxNameLen = int(nameLen)
 		}
@@ -478,8 +750,11 @@ func calcColumnDetails(cols []*sql.ColumnType, fixed int64, variable int64) ([]c
 			columnDetails[i].displayWidth = max64(length, nameLen)
 		// Variable length types
 		// TODO: Fix BINARY once we have a driver with fix for https://github.com/denisenkom/go-mssqldb/issues/685
-		case "XML", "TEXT", "NTEXT", "IMAGE", "BINARY":
+		case "XML", "TEXT", "NTEXT", "IMAGE", "BINARY", "GEOMETRY", "GEOGRAPHY":
 			columnDetails[i].displayWidth = variable
+		// Paths of hierarchyid values are short, so they don't use the width of their serialized form
+		case "HIERARCHYID":
+			columnDetails[i].displayWidth = max64(variable, nameLen)
 		default:
 			columnDetails[i].displayWidth = length
 		}
@@ -491,82 +766,282 @@ func calcColumnDetails(cols []*sql.ColumnType, fixed int64, variable int64) ([]c
 	return columnDetails, maxNameLen
 }
 
-// scanRow fetches the next row and converts each value to the appropriate string representation
-func (f *sqlCmdFormatterType) scanRow(rows *sql.Rows) ([]string, error) {
-	r := make([]interface{}, len(f.columnDetails))
-	for i := range r {
-		r[i] = new(interface{})
+// addRow implements AddRow for formatter f. It scans the row, passes the values to
+// f.AddValues and returns the unformatted value of the first column, which sets the exit code of :EXIT(query).
+func (f *sqlCmdFormatterType) addRow(rows *sql.Rows, formatter RowFormatter) string {
+	b := newRowBuffer(len(f.columnDetails))
+	if err := b.scan(rows); err != nil {
+		formatter.AddError(err)
+		return ""
 	}
-	if err := rows.Scan(r...); err != nil {
-		return nil, err
+	formatter.AddValues(b.values)
+	return f.firstValue(b.values)
+}
+
+// firstValue returns the unformatted value of the first column of a row
+func (f *sqlCmdFormatterType) firstValue(values []interface{}) string {
+	if len(values) == 0 {
+		return ""
 	}
-	row := make([]string, len(f.columnDetails))
-	for n, z := range r {
-		j := z.(*interface{})
-		if *j == nil {
-			row[n] = "NULL"
-		} else {
-			switch x := (*j).(type) {
-			case []byte:
-				if isBinaryDataType(&f.columnDetails[n].col) {
-					row[n] = decodeBinary(x)
-				} else if f.columnDetails[n].col.DatabaseTypeName() == "UNIQUEIDENTIFIER" {
-					// Unscramble the guid
-					// see https://github.com/denisenkom/go-mssqldb/issues/56
-					x[0], x[1], x[2], x[3] = x[3], x[2], x[1], x[0]
-					x[4], x[5] = x[5], x[4]
-					x[6], x[7] = x[7], x[6]
-					if guid, err := uuid.FromBytes(x); err == nil {
-						row[n] = guid.String()
-					} else {
-						// this should never happen
-						row[n] = uuid.New().String()
-					}
-				} else {
-					row[n] = string(x)
-				}
-			case string:
-				row[n] = x
-			case time.Time:
-				// Go lacks any way to get the user's preferred time format or even the system default
-				switch f.columnDetails[n].col.DatabaseTypeName() {
-				case "DATE":
-					row[n] = x.Format("2006-01-02")
-				case "DATETIME":
-					row[n] = x.Format(dateTimeFormatString(3, false))
-				case "DATETIME2":
-					row[n] = x.Format(dateTimeFormatString(f.columnDetails[n].scale, false))
-				case "SMALLDATETIME":
-					row[n] = x.Format(dateTimeFormatString(0, false))
-				case "DATETIMEOFFSET":
-					row[n] = x.Format(dateTimeFormatString(f.columnDetails[n].scale, true))
-				case "TIME":
-					format := "15:04:05"
-					if f.columnDetails[n].scale > 0 {
-						format = fmt.Sprintf("%s.%0*d", format, f.columnDetails[n].scale, 0)
-					}
-					row[n] = x.Format(format)
-				default:
-					row[n] = x.Format(time.RFC3339)
-				}
-			case fmt.Stringer:
-				row[n] = x.String()
-			// not sure why go-mssql reports bit as bool
-			case bool:
-				if x {
-					row[n] = "1"
-				} else {
-					row[n] = "0"
-				}
-			default:
-				var err error
-				if row[n], err = fmt.Sprintf("%v", x), nil; err != nil {
-					return nil, err
-				}
+	return f.formatValue(0, values[0])
+}
+
+// displayRow converts each value of a row to its display string
+func (f *sqlCmdFormatterType) displayRow(values []interface{}) []string {
+	row := make([]string, len(values))
+	for n, v := range values {
+		row[n] = f.displayValue(n, v)
+	}
+	return row
+}
+
+// rowBuffer receives the values of the rows of a result set. Its slices are reused for every row.
+type rowBuffer struct {
+	values []interface{}
+	dest   []interface{}
+}
+
+func newRowBuffer(columns int) *rowBuffer {
+	b := &rowBuffer{values: make([]interface{}, columns), dest: make([]interface{}, columns)}
+	for i := range b.dest {
+		b.dest[i] = &b.values[i]
+	}
+	return b
+}
+
+// scan fetches the current row. Each value is stored as provided by the driver.
+func (b *rowBuffer) scan(rows *sql.Rows) error {
+	return rows.Scan(b.dest...)
+}
+
+// formatValue converts the driver value of column n to its string representation
+func (f *sqlCmdFormatterType) formatValue(n int, v interface{}) string {
+	if v == nil {
+		return "NULL"
+	}
+	switch x := v.(type) {
+	case []byte:
+		if isBinaryDataType(&f.columnDetails[n].col) {
+			return decodeBinary(x)
+		}
+		switch typeName := f.columnDetails[n].col.DatabaseTypeName(); typeName {
+		case "UNIQUEIDENTIFIER":
+			return decodeUniqueIdentifier(x)
+		case "GEOMETRY", "GEOGRAPHY":
+			return f.formatSpatial(x, typeName == "GEOGRAPHY")
+		case "HIERARCHYID":
+			if path, err := decodeHierarchyID(x); err == nil {
+				return path
+			}
+			return "0x" + decodeBinary(x)
+		// The driver returns the bytes of sql_variant values of the decimal, money, binary and
+		// uniqueidentifier base types without saying which one it is, so all of them print as hex
+		case "SQL_VARIANT":
+			return "0x" + decodeBinary(x)
+		}
+		return string(x)
+	case string:
+		return x
+	case time.Time:
+		// Go lacks any way to get the user's preferred time format or even the system default
+		switch f.columnDetails[n].col.DatabaseTypeName() {
+		case "DATE":
+			return x.Format("2006-01-02")
+		case "DATETIME":
+			return x.Format(dateTimeFormatString(3, false))
+		case "DATETIME2":
+			return x.Format(dateTimeFormatString(f.columnDetails[n].scale, false))
+		case "SMALLDATETIME":
+			return x.Format(dateTimeFormatString(0, false))
+		case "DATETIMEOFFSET":
+			return x.Format(dateTimeFormatString(f.columnDetails[n].scale, true))
+		case "TIME":
+			format := "15:04:05"
+			if f.columnDetails[n].scale > 0 {
+				format = fmt.Sprintf("%s.%0*d", format, f.columnDetails[n].scale, 0)
+			}
+			return x.Format(format)
+		case "SQL_VARIANT":
+			if _, offset := x.Zone(); offset != 0 {
+				return x.Format("2006-01-02 15:04:05.9999999 -07:00")
+			}
+			return x.Format("2006-01-02 15:04:05.9999999")
+		default:
+			return x.Format(time.RFC3339)
+		}
+	case fmt.Stringer:
+		return x.String()
+	// not sure why go-mssql reports bit as bool
+	case bool:
+		if x {
+			return "1"
+		}
+		return "0"
+	default:
+		return fmt.Sprintf("%v", x)
+	}
+}
+
+// displayValue converts the driver value of column n to the string the text-based formatters print.
+// It applies the SQLCMDNULLDISPLAY, SQLCMDDATETIMEFORMAT and SQLCMDNUMBERFORMAT variables.
+func (f *sqlCmdFormatterType) displayValue(n int, v interface{}) string {
+	if v == nil {
+		return f.vars.NullDisplay()
+	}
+	c := &f.columnDetails[n]
+	typeName := c.col.DatabaseTypeName()
+	switch x := v.(type) {
+	case time.Time:
+		switch f.vars.DateTimeFormat() {
+		case "iso8601":
+			return x.Format(isoDateTimeFormatString(typeName, c.scale))
+		case "epoch":
+			return epochString(x, typeName, c.scale)
+		}
+	case float64:
+		if isNumericType(typeName) && f.vars.NumberFormat() != "" {
+			return formatNumber(strconv.FormatFloat(x, 'f', -1, 64), f.vars.NumberFormat())
+		}
+	case float32:
+		if isNumericType(typeName) && f.vars.NumberFormat() != "" {
+			return formatNumber(strconv.FormatFloat(float64(x), 'f', -1, 32), f.vars.NumberFormat())
+		}
+	default:
+		if isNumericType(typeName) && f.vars.NumberFormat() != "" {
+			return formatNumber(f.formatValue(n, v), f.vars.NumberFormat())
+		}
+	}
+	return f.formatValue(n, v)
+}
+
+// epochString returns the number of seconds between 1970-01-01 UTC and t, with the
+// fractional digits of the column type. TIME values are the seconds since midnight.
+func epochString(t time.Time, typeName string, scale int) string {
+	switch typeName {
+	case "DATETIME":
+		scale = 3
+	case "SMALLDATETIME", "DATE":
+		scale = 0
+	}
+	var seconds int64
+	if typeName == "TIME" {
+		seconds = int64(t.Hour()*3600 + t.Minute()*60 + t.Second())
+	} else {
+		seconds = t.Unix()
+	}
+	r := new(big.Rat).SetFrac64(int64(t.Nanosecond()), int64(time.Second))
+	r.Add(r, new(big.Rat).SetInt64(seconds))
+	return r.FloatString(scale)
+}
+
+// numberFormatPattern matches the supported SQLCMDNUMBERFORMAT patterns: an optional
+// thousands separator followed by a fixed number of decimal places, such as "#,##0.00"
+var numberFormatPattern = regexp.MustCompile(`^(#,##)?0(\.(0+))?$`)
+
+// formatNumber rounds the decimal number s and adds thousands separators as specified
+// by pattern. s is returned unchanged when either isn't valid.
+func formatNumber(s string, pattern string) string {
+	m := numberFormatPattern.FindStringSubmatch(pattern)
+	r, ok := new(big.Rat).SetString(s)
+	if m == nil || !ok {
+		return s
+	}
+	s = r.FloatString(len(m[3]))
+	if m[1] == "" {
+		return s
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	digits, fraction, _ := strings.Cut(s, ".")
+	b := new(strings.Builder)
+	b.WriteString(sign)
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	if fraction != "" {
+		b.WriteString("." + fraction)
+	}
+	return b.String()
+}
+
+// widenFormattedNumbers makes room in numeric columns for the separators and decimal places added by SQLCMDNUMBERFORMAT
+func (f *sqlCmdFormatterType) widenFormattedNumbers() {
+	m := numberFormatPattern.FindStringSubmatch(f.vars.NumberFormat())
+	if m == nil {
+		return
+	}
+	for i := range f.columnDetails {
+		c := &f.columnDetails[i]
+		var digits int64
+		switch c.col.DatabaseTypeName() {
+		case "TINYINT":
+			digits = 3
+		case "SMALLINT":
+			digits = 5
+		case "INT":
+			digits = 10
+		case "BIGINT":
+			digits = 19
+		case "SMALLMONEY":
+			digits = 6
+		case "MONEY":
+			digits = 15
+		case "DECIMAL", "NUMERIC":
+			digits = max64(int64(c.precision-c.scale), 1)
+		case "REAL", "FLOAT":
+			digits = 24
+		default:
+			continue
+		}
+		// one more for the sign
+		width := digits + 1
+		if m[1] != "" {
+			width += (digits - 1) / 3
+		}
+		if m[3] != "" {
+			width += int64(len(m[3])) + 1
+		}
+		if c.displayWidth > 0 {
+			c.displayWidth = max64(c.displayWidth, width)
+		}
+	}
+}
+
+// formatSpatial converts a geometry or geography value to Well-Known Text, or to Extended
+// Well-Known Text with the SRID when SQLCMDSPATIALFORMAT is ewkt.
+// Values are printed as hex when SQLCMDSPATIALFORMAT is hex or the value can't be decoded.
+func (f *sqlCmdFormatterType) formatSpatial(b []byte, geography bool) string {
+	format := f.vars.SpatialFormat()
+	if format != "hex" {
+		if wkt, srid, err := decodeSpatial(b, geography); err == nil {
+			if format == "ewkt" && srid != 0 {
+				return "SRID=" + strconv.Itoa(int(srid)) + ";" + wkt
 			}
+			return wkt
 		}
 	}
-	return row, nil
+	return "0x" + decodeBinary(b)
+}
+
+// decodeUniqueIdentifier converts the driver's byte order of a uniqueidentifier to its string form
+func decodeUniqueIdentifier(b []byte) string {
+	// Unscramble the guid
+	// see https://github.com/denisenkom/go-mssqldb/issues/56
+	x := make([]byte, len(b))
+	copy(x, b)
+	x[0], x[1], x[2], x[3] = x[3], x[2], x[1], x[0]
+	x[4], x[5] = x[5], x[4]
+	x[6], x[7] = x[7], x[6]
+	if guid, err := uuid.FromBytes(x); err == nil {
+		return guid.String()
+	}
+	// this should never happen
+	return uuid.New().String()
 }
 
 func dateTimeFormatString(scale int, addOffset bool) string {
@@ -593,11 +1068,11 @@ func (f *sqlCmdFormatterType) printColumnValue(val string, col int) {
 	}
 
 	s.WriteString(val)
-	r := []rune(val)
+	width := int64(stringWidth(val))
 	if !f.xml && f.format == "horizontal" {
 		if !f.removeTrailingSpaces {
 			if f.vars.MaxVarColumnWidth() != 0 || !isLargeVariableType(&c.col) {
-				padding := c.displayWidth - min64(c.displayWidth, int64(len(r)))
+				padding := c.displayWidth - min64(c.displayWidth, width)
 				if padding > 0 {
 					if c.leftJustify {
 						s = padRight(s, padding, " ")
@@ -608,11 +1083,14 @@ func (f *sqlCmdFormatterType) printColumnValue(val string, col int) {
 			}
 		}
 
-		r = []rune(s.String())
+		width = int64(stringWidth(s.String()))
 	}
-	if !f.xml && (c.displayWidth > 0 && int64(len(r)) > c.displayWidth) {
+	// Values are cut by terminal display width, not by characters, so a value of wide characters
+	// takes no more columns than the heading and the rest of the row stays aligned
+	if !f.xml && (c.displayWidth > 0 && width > c.displayWidth) {
+		v := s.String()
 		s.Reset()
-		s.WriteString(string(r[:c.displayWidth]))
+		s.WriteString(truncateWidth(v, int(c.displayWidth)))
 	}
 	clr := color.TextTypeCell
 	if f.xml {
@@ -635,6 +1113,14 @@ func (f *sqlCmdFormatterType) mustWriteErr(s string) {
 	}
 }
 
+// mustWriteMessage writes a message to the err Writer, for formatters whose output has no place for messages
+func (f *sqlCmdFormatterType) mustWriteMessage(msg string) {
+	err := f.colorizer.Write(f.err, msg+SqlcmdEol, f.vars.ColorScheme(), color.TextTypeWarning)
+	if err != nil {
+		panic(err)
+	}
+}
+
 func isLargeVariableType(col *sql.ColumnType) bool {
 	l, _ := col.Length()
 	switch col.DatabaseTypeName() {
@@ -7,9 +7,9 @@ import (
 	"database/sql"
 	"os"
 	"strings"
-	"unicode/utf8"
 
 	"github.com/microsoft/go-sqlcmd/internal/color"
+	"github.com/rivo/uniseg"
 	"golang.org/x/term"
 )
 
@@ -32,34 +32,36 @@ func NewSQLCmdAsciiFormatter(vars *Variables, removeTrailingSpaces bool, ccb Con
 }
 
 func (f *asciiFormatter) BeginResultSet(cols []*sql.ColumnType) {
-	f.sqlCmdFormatterType.BeginResultSet(cols)
+	f.BeginColumns(newColumns(cols, f.vars))
+}
+
+func (f *asciiFormatter) BeginColumns(columns []Column) {
+	f.sqlCmdFormatterType.BeginColumns(columns)
 	f.rows = make([][]string, 0)
 	f.colWidths = make([]int, len(f.columnDetails))
 	for i, c := range f.columnDetails {
-		f.colWidths[i] = utf8.RuneCountInString(c.col.Name())
+		f.colWidths[i] = stringWidth(c.col.Name())
 	}
 }
 
+// AddRow adds the current row to the table
 func (f *asciiFormatter) AddRow(row *sql.Rows) string {
-	values, err := f.scanRow(row)
-	if err != nil {
-		f.mustWriteErr(err.Error())
-		return ""
-	}
+	return f.addRow(row, f)
+}
+
+// AddValues adds a row to the table
+func (f *asciiFormatter) AddValues(row []interface{}) {
+	values := f.displayRow(row)
 	f.rows = append(f.rows, values)
 	f.rowcount++
 	for i, val := range values {
 		if i < len(f.colWidths) {
-			l := utf8.RuneCountInString(val)
+			l := f.cellWidth(val)
 			if l > f.colWidths[i] {
 				f.colWidths[i] = l
 			}
 		}
 	}
-	if len(values) > 0 {
-		return values[0]
-	}
-	return ""
 }
 
 func (f *asciiFormatter) EndResultSet() {
@@ -87,6 +89,10 @@ func (f *asciiFormatter) printAsciiTable() {
 		maxColContentWidth = 1
 	}
 
+	if wrap := f.vars.CellWrapWidth(); wrap > 0 && wrap < maxColContentWidth {
+		maxColContentWidth = wrap
+	}
+
 	for i := range f.colWidths {
 		if f.colWidths[i] > maxColContentWidth {
 			f.colWidths[i] = maxColContentWidth
@@ -148,46 +154,121 @@ func (f *asciiFormatter) printTableSegment(colWidths []int, startCol, endCol int
 	f.writeOut(header+SqlcmdEol, color.TextTypeHeader)
 	f.writeOut(divider+SqlcmdEol, color.TextTypeSeparator)
 
+	wrap := f.vars.CellWrapWidth() > 0
 	for _, row := range f.rows {
-		line := sep
+		// Each cell is one line unless wrapping splits it into several
+		cells := make([][]string, endCol-startCol+1)
+		height := 1
 		for i := startCol; i <= endCol; i++ {
 			val := ""
 			if i < len(row) {
 				val = row[i]
 			}
-			isNumeric := isNumericType(f.columnDetails[i].col.DatabaseTypeName())
-
-			if isNumeric {
-				line += " " + padLeftString(val, colWidths[i]) + " " + sep
+			if wrap {
+				cells[i-startCol] = wrapCell(val, colWidths[i], f.vars.CellWrapLines())
+				height = max(height, len(cells[i-startCol]))
 			} else {
-				line += " " + padRightString(val, colWidths[i]) + " " + sep
+				cells[i-startCol] = []string{val}
+			}
+		}
+		for l := 0; l < height; l++ {
+			line := sep
+			for i := startCol; i <= endCol; i++ {
+				val := ""
+				if c := cells[i-startCol]; l < len(c) {
+					val = c[l]
+				}
+				isNumeric := isNumericType(f.columnDetails[i].col.DatabaseTypeName())
+
+				if isNumeric {
+					line += " " + padLeftString(val, colWidths[i]) + " " + sep
+				} else {
+					line += " " + padRightString(val, colWidths[i]) + " " + sep
+				}
 			}
+			f.writeOut(line+SqlcmdEol, color.TextTypeCell)
 		}
-		f.writeOut(line+SqlcmdEol, color.TextTypeCell)
 	}
 	f.writeOut(divider+SqlcmdEol, color.TextTypeSeparator)
 }
 
+// cellWidth returns the width a value needs in the table. Wrapped values start a new line at each line break.
+func (f *asciiFormatter) cellWidth(val string) int {
+	if f.vars.CellWrapWidth() <= 0 {
+		return stringWidth(val)
+	}
+	w := 0
+	for _, l := range strings.Split(val, "\n") {
+		w = max(w, stringWidth(strings.TrimSuffix(l, "\r")))
+	}
+	return w
+}
+
+// wrapCell splits val into lines no wider than width, breaking between words where possible.
+// Line breaks in val start a new line. When maxLines > 0 and val needs more lines,
+// the last line ends with an ellipsis, unless the cell is too narrow for one.
+func wrapCell(val string, width int, maxLines int) []string {
+	var lines []string
+	line := ""
+	state := -1
+	for val != "" {
+		var segment string
+		var mustBreak bool
+		segment, val, mustBreak, state = uniseg.FirstLineSegmentInString(val, state)
+		segment = strings.TrimRight(segment, "\r\n")
+		if line != "" && stringWidth(line+strings.TrimRight(segment, " ")) > width {
+			lines = append(lines, strings.TrimRight(line, " "))
+			line = ""
+		}
+		line += segment
+		// Words wider than the cell are split where the width runs out
+		for stringWidth(strings.TrimRight(line, " ")) > width {
+			head := strings.TrimRight(truncateWidth(line, width), " ")
+			if head == "" {
+				// a character wider than the cell
+				head, _, _, _ = uniseg.FirstGraphemeClusterInString(line, -1)
+			}
+			lines = append(lines, head)
+			line = line[len(head):]
+		}
+		if mustBreak && val != "" {
+			lines = append(lines, strings.TrimRight(line, " "))
+			line = ""
+		}
+	}
+	lines = append(lines, strings.TrimRight(line, " "))
+	if maxLines > 0 && len(lines) > maxLines {
+		lines = lines[:maxLines]
+		if width < 3 {
+			return lines
+		}
+		last := lines[maxLines-1]
+		if stringWidth(last)+3 > width {
+			last = strings.TrimRight(truncateWidth(last, width-3), " ")
+		}
+		lines[maxLines-1] = last + "..."
+	}
+	return lines
+}
+
 func padRightString(s string, width int) string {
-	l := utf8.RuneCountInString(s)
+	l := stringWidth(s)
 	if l > width {
-		r := []rune(s)
 		if width >= 3 {
-			return string(r[:width-3]) + "..."
+			return truncateWidth(s, width-3) + "..."
 		}
-		return string(r[:width])
+		return truncateWidth(s, width)
 	}
 	return s + strings.Repeat(" ", width-l)
 }
 
 func padLeftString(s string, width int) string {
-	l := utf8.RuneCountInString(s)
+	l := stringWidth(s)
 	if l > width {
-		r := []rune(s)
 		if width >= 3 {
-			return string(r[:width-3]) + "..."
+			return truncateWidth(s, width-3) + "..."
 		}
-		return string(r[:width])
+		return truncateWidth(s, width)
 	}
 	return strings.Repeat(" ", width-l) + s
 }
@@ -6,12 +6,17 @@ package sqlcmd
 import (
 	"bytes"
 	"database/sql"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"unsafe"
 
 	"github.com/microsoft/go-sqlcmd/internal/color"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func setColumnInfo(c *sql.ColumnType, name string, dbType string) {
@@ -138,3 +143,125 @@ func TestAsciiFormatterTruncation(t *testing.T) {
 	// Verify it does NOT contain the full string
 	assert.NotContains(t, output, "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")
 }
+
+// mixedScriptRows reads the test corpus of values in several scripts with their expected display widths
+func mixedScriptRows(t *testing.T) [][]string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", "mixedscript.txt"))
+	require.NoError(t, err)
+	var rows [][]string
+	for _, line := range strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		require.Len(t, fields, 3, line)
+		rows = append(rows, fields)
+	}
+	return rows
+}
+
+func TestStringWidthMixedScripts(t *testing.T) {
+	for _, row := range mixedScriptRows(t) {
+		assert.Equal(t, row[2], strconv.Itoa(stringWidth(row[1])), "display width of %s", row[0])
+	}
+	assert.Equal(t, "a日 ", truncateWidth("a日本", 4), "a wide character that doesn't fit is replaced by a space")
+	assert.Equal(t, " 本語", skipWidth("日本語", 1))
+}
+
+func TestMixedScriptAlignment(t *testing.T) {
+	rows := mixedScriptRows(t)
+	vars := InitializeVariables(false)
+
+	buf := new(bytes.Buffer)
+	ascii := NewSQLCmdAsciiFormatter(vars, false, ControlIgnore).(*asciiFormatter)
+	ascii.BeginBatch("", vars, buf, buf)
+	ascii.columnDetails = make([]columnDetail, 2)
+	setColumnInfo(&ascii.columnDetails[0].col, "name", "NVARCHAR")
+	setColumnInfo(&ascii.columnDetails[1].col, "値", "NVARCHAR")
+	ascii.colWidths = []int{4, 2}
+	for _, row := range rows {
+		ascii.rows = append(ascii.rows, row[:2])
+		for i := range ascii.colWidths {
+			ascii.colWidths[i] = max(ascii.colWidths[i], stringWidth(row[i]))
+		}
+	}
+	ascii.printAsciiTable()
+	assertSameWidth(t, buf.String(), len(rows)+4)
+
+	buf.Reset()
+	f := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore).(*sqlCmdFormatterType)
+	f.BeginBatch("", vars, buf, buf)
+	f.columnDetails = make([]columnDetail, 2)
+	setColumnInfo(&f.columnDetails[0].col, "name", "NVARCHAR")
+	setColumnInfo(&f.columnDetails[1].col, "値", "NVARCHAR")
+	f.columnDetails[0].displayWidth, f.columnDetails[1].displayWidth = 10, 14
+	f.printColumnHeadings()
+	for _, row := range rows {
+		f.printColumnValue(row[0], 0)
+		f.writeOut(vars.ColumnSeparator(), color.TextTypeSeparator)
+		f.printColumnValue(row[1], 1)
+		f.writeOut(SqlcmdEol, color.TextTypeNormal)
+	}
+	assert.Contains(t, buf.String(), "SQL Server デ "+SqlcmdEol, "values longer than the column are truncated at the column width")
+	assert.Contains(t, buf.String(), "a日本語テキス "+SqlcmdEol, "values of wide characters are truncated at the column display width")
+	assertSameWidth(t, buf.String(), len(rows)+2)
+}
+
+// assertSameWidth checks that every line of s takes the same number of terminal columns
+func assertSameWidth(t *testing.T, s string, lines int) {
+	t.Helper()
+	l := strings.Split(strings.TrimSuffix(s, SqlcmdEol), SqlcmdEol)
+	require.Len(t, l, lines, s)
+	for _, line := range l[1:] {
+		assert.Equal(t, stringWidth(l[0]), stringWidth(line), "misaligned line %q in%s%s", line, SqlcmdEol, s)
+	}
+}
+
+func TestWrapCell(t *testing.T) {
+	assert.Equal(t, []string{"the quick", "brown fox", "jumps"}, wrapCell("the quick brown fox jumps", 10, 0))
+	assert.Equal(t, []string{"abcdefghij", "klm"}, wrapCell("abcdefghijklm", 10, 0), "long words are split")
+	assert.Equal(t, []string{`{"a": 1,`, `"b": 2}`}, wrapCell("{\"a\": 1,\r\n\"b\": 2}", 10, 0), "line breaks start a new line")
+	assert.Equal(t, []string{"日本語の文", "章です"}, wrapCell("日本語の文章です", 10, 0), "ideographs wrap between characters")
+	assert.Equal(t, []string{"the quick", "brown f..."}, wrapCell("the quick brown fox jumps", 10, 2), "the ellipsis replaces the end of a full line")
+	assert.Equal(t, []string{"the..."}, wrapCell("the quick", 6, 1))
+	assert.Equal(t, []string{"a", "b"}, wrapCell("abc def", 1, 2), "cells narrower than the ellipsis are cut without it")
+	assert.Equal(t, []string{"ab", "c"}, wrapCell("abc def", 2, 2), "cells narrower than the ellipsis are cut without it")
+	assert.Equal(t, []string{"..."}, wrapCell("abc def", 3, 1))
+	assert.Equal(t, []string{""}, wrapCell("", 10, 0))
+}
+
+func TestAsciiFormatterCellWrap(t *testing.T) {
+	vars := InitializeVariables(false)
+	vars.Set(SQLCMDCOLWIDTH, "80")
+	vars.Set(SQLCMDCELLWRAP, "12")
+	vars.Set(SQLCMDCELLWRAPLINES, "3")
+
+	buf := new(bytes.Buffer)
+	f := NewSQLCmdAsciiFormatter(vars, false, ControlIgnore).(*asciiFormatter)
+	f.BeginBatch("", vars, buf, buf)
+	f.columnDetails = make([]columnDetail, 2)
+	setColumnInfo(&f.columnDetails[0].col, "id", "INT")
+	setColumnInfo(&f.columnDetails[1].col, "message", "NVARCHAR")
+	f.rows = [][]string{
+		{"1", "Cannot insert the value NULL into column 'name', table 'tempdb.dbo.t'; column does not allow nulls."},
+		{"2", "short"},
+	}
+	f.colWidths = []int{2, 7}
+	for _, row := range f.rows {
+		for i, v := range row {
+			f.colWidths[i] = max(f.colWidths[i], f.cellWidth(v))
+		}
+	}
+	f.printAsciiTable()
+
+	expected := `+----+--------------+` + SqlcmdEol +
+		`| id | message      |` + SqlcmdEol +
+		`+----+--------------+` + SqlcmdEol +
+		`|  1 | Cannot       |` + SqlcmdEol +
+		`|    | insert the   |` + SqlcmdEol +
+		`|    | value NUL... |` + SqlcmdEol +
+		`|  2 | short        |` + SqlcmdEol +
+		`+----+--------------+` + SqlcmdEol
+	assert.Equal(t, expected, buf.String())
+}
@@ -0,0 +1,137 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/microsoft/go-sqlcmd/internal/color"
+)
+
+// csvEol is the record terminator required by RFC 4180
+const csvEol = "\r\n"
+
+// csvFormatter writes result sets as RFC 4180 comma separated values.
+// Messages such as row counts are written to the error stream so the output contains only data.
+type csvFormatter struct {
+	*sqlCmdFormatterType
+	resultSets int
+}
+
+// NewSQLCmdCsvFormatter returns a formatter that renders result sets as CSV
+func NewSQLCmdCsvFormatter(vars *Variables, ccb ControlCharacterBehavior) Formatter {
+	return &csvFormatter{
+		sqlCmdFormatterType: &sqlCmdFormatterType{
+			format:    "csv",
+			colorizer: color.New(false),
+			ccb:       ccb,
+			vars:      vars,
+		},
+	}
+}
+
+// csvSeparator returns SQLCMDCOLSEP when it has been changed from the default, otherwise a comma
+func (f *csvFormatter) csvSeparator() string {
+	sep := f.vars.ColumnSeparator()
+	if sep == "" || sep == " " {
+		return ","
+	}
+	return sep
+}
+
+func (f *csvFormatter) BeginResultSet(cols []*sql.ColumnType) {
+	f.BeginColumns(newColumns(cols, f.vars))
+}
+
+func (f *csvFormatter) BeginColumns(columns []Column) {
+	f.sqlCmdFormatterType.BeginColumns(columns)
+	if f.xml {
+		return
+	}
+	if f.resultSets > 0 {
+		f.mustWriteOut(csvEol, color.TextTypeNormal)
+	}
+	f.resultSets++
+	if f.vars.RowsBetweenHeaders() > -1 {
+		names := make([]string, len(f.columnDetails))
+		for i, c := range f.columnDetails {
+			names[i] = c.col.Name()
+		}
+		f.writeRecord(names, nil, color.TextTypeHeader)
+	}
+}
+
+func (f *csvFormatter) EndResultSet() {
+	if f.xml {
+		f.sqlCmdFormatterType.EndResultSet()
+	}
+}
+
+// AddRow writes the current row as a CSV record
+func (f *csvFormatter) AddRow(row *sql.Rows) string {
+	return f.addRow(row, f)
+}
+
+// AddValues writes a row as a CSV record
+func (f *csvFormatter) AddValues(values []interface{}) {
+	if f.xml {
+		f.sqlCmdFormatterType.AddValues(values)
+		return
+	}
+	fields := make([]string, len(values))
+	nulls := make([]bool, len(values))
+	for i, v := range values {
+		if v == nil {
+			fields[i] = f.vars.CsvNullDisplay()
+			nulls[i] = true
+			continue
+		}
+		val := f.displayValue(i, v)
+		c := &f.columnDetails[i].col
+		if isNeedingControlCharacterTreatment(c) {
+			val = applyControlCharacterBehavior(val, f.ccb)
+		}
+		if _, file := v.(blobFile); !file && isNeedingHexPrefix(c) {
+			val = "0x" + val
+		}
+		fields[i] = val
+	}
+	f.writeRecord(fields, nulls, color.TextTypeCell)
+	f.rowcount++
+}
+
+// AddMessage writes the message to the error stream so row counts and PRINT output don't corrupt the data
+func (f *csvFormatter) AddMessage(msg string) {
+	if !f.xml {
+		f.mustWriteMessage(msg)
+	}
+}
+
+// writeRecord writes one CSV record. Fields flagged in nulls are written without quotes.
+func (f *csvFormatter) writeRecord(fields []string, nulls []bool, t color.TextType) {
+	sep := f.csvSeparator()
+	b := new(strings.Builder)
+	for i, field := range fields {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		if nulls != nil && nulls[i] {
+			b.WriteString(field)
+		} else {
+			b.WriteString(csvQuote(field, sep))
+		}
+	}
+	b.WriteString(csvEol)
+	f.mustWriteOut(b.String(), t)
+}
+
+// csvQuote encloses the field in double quotes when RFC 4180 requires it.
+// Empty strings are always quoted so they can be told apart from NULL.
+func csvQuote(field string, sep string) string {
+	if field != "" && !strings.ContainsAny(field, "\"\r\n") && !strings.Contains(field, sep) {
+		return field
+	}
+	return `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+}
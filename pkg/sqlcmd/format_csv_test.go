@@ -0,0 +1,63 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCsvQuote(t *testing.T) {
+	tests := []struct {
+		field  string
+		quoted string
+	}{
+		{"plain", "plain"},
+		{"", `""`},
+		{"a,b", `"a,b"`},
+		{`say "hi"`, `"say ""hi"""`},
+		{"two\r\nlines", "\"two\r\nlines\""},
+		{"new\nline", "\"new\nline\""},
+		{" padded ", " padded "},
+	}
+	for _, test := range tests {
+		assert.Equalf(t, test.quoted, csvQuote(test.field, ","), "Incorrect quoting for %q", test.field)
+	}
+	assert.Equal(t, `"a;b"`, csvQuote("a;b", ";"), "custom separator must be quoted")
+}
+
+func TestCsvFormatterWriteRecord(t *testing.T) {
+	vars := InitializeVariables(false)
+	vars.Set(SQLCMDFORMAT, "csv")
+	f := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore).(*csvFormatter)
+	out, errOut := new(strings.Builder), new(strings.Builder)
+	f.BeginBatch("", vars, out, errOut)
+
+	f.writeRecord([]string{"id", "comment"}, nil, 0)
+	f.writeRecord([]string{"1", "has, comma and \"quotes\"\nand a newline"}, []bool{false, false}, 0)
+	f.writeRecord([]string{"2", ""}, []bool{false, true}, 0)
+	f.writeRecord([]string{"3", ""}, []bool{false, false}, 0)
+	f.AddMessage("(3 rows affected)")
+
+	assert.Equal(t, "id,comment\r\n1,\"has, comma and \"\"quotes\"\"\nand a newline\"\r\n2,\r\n3,\"\"\r\n", out.String())
+	records, err := csv.NewReader(strings.NewReader(out.String())).ReadAll()
+	require.NoError(t, err, "output must be parseable CSV")
+	assert.Equal(t, [][]string{{"id", "comment"}, {"1", "has, comma and \"quotes\"\nand a newline"}, {"2", ""}, {"3", ""}}, records)
+	assert.Equal(t, "(3 rows affected)"+SqlcmdEol, errOut.String(), "messages are written to the error stream")
+}
+
+func TestCsvFormatterSeparatorAndNullToken(t *testing.T) {
+	vars := InitializeVariables(false)
+	vars.Set(SQLCMDCOLSEP, "\t")
+	vars.Set(SQLCMDCSVNULL, `\N`)
+	f := NewSQLCmdCsvFormatter(vars, ControlIgnore).(*csvFormatter)
+	out := new(strings.Builder)
+	f.BeginBatch("", vars, out, out)
+	f.writeRecord([]string{"a\tb", vars.CsvNullDisplay()}, []bool{false, true}, 0)
+	assert.Equal(t, "\"a\tb\"\t\\N\r\n", out.String())
+}
@@ -0,0 +1,202 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"database/sql"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/microsoft/go-sqlcmd/internal/color"
+	"github.com/microsoft/go-sqlcmd/internal/localizer"
+)
+
+// htmlDocumentHeader starts the standalone document. The closing body and html
+// tags are optional in HTML5, which lets the document grow one batch at a time.
+const htmlDocumentHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>sqlcmd</title>
+<style>
+body { font-family: Segoe UI, Helvetica, Arial, sans-serif; font-size: 14px; color: #222; }
+section.batch { margin-bottom: 2em; }
+pre.query { background: #f4f4f4; padding: 0.5em; }
+table { border-collapse: collapse; margin: 0.5em 0; }
+th, td { border: 1px solid #ccc; padding: 2px 6px; vertical-align: top; white-space: pre-wrap; }
+th { background: #eaeaea; text-align: left; }
+td.num { text-align: right; }
+td.null { color: #999; font-style: italic; }
+td.bin code { font-size: 12px; }
+p.message { color: #555; }
+pre.error { color: #b00020; background: #fdecea; padding: 0.5em; }
+pre.xml { background: #f4f4f4; padding: 0.5em; }
+</style>
+</head>
+<body>
+`
+
+// htmlClasses maps the colorizer text types to the CSS classes used in the document
+var htmlClasses = map[color.TextType]string{
+	color.TextTypeError:   "error",
+	color.TextTypeWarning: "message",
+	color.TextTypeXml:     "xml",
+}
+
+// htmlFormatter writes a standalone HTML document. Each batch is a section
+// and each result set is a table.
+type htmlFormatter struct {
+	*sqlCmdFormatterType
+	// document is the file that already received the document header
+	document outputFile
+	batches  int
+}
+
+// NewSQLCmdHtmlFormatter returns a formatter that renders results as an HTML document
+func NewSQLCmdHtmlFormatter(vars *Variables, ccb ControlCharacterBehavior) Formatter {
+	return &htmlFormatter{
+		sqlCmdFormatterType: &sqlCmdFormatterType{
+			format:    "html",
+			colorizer: color.New(false),
+			ccb:       ccb,
+			vars:      vars,
+		},
+	}
+}
+
+// BeginBatch starts a new document when the output file has changed, then opens a section for the batch
+func (f *htmlFormatter) BeginBatch(query string, vars *Variables, out io.Writer, err io.Writer) {
+	f.sqlCmdFormatterType.BeginBatch(query, vars, out, err)
+	if f.beginDocument() {
+		f.batches = 0
+	}
+	f.batches++
+	f.mustWriteOut(`<section class="batch">`+SqlcmdEol, color.TextTypeNormal)
+	f.mustWriteOut(`<details><summary>`+html.EscapeString(localizer.Sprintf("Batch %d", f.batches))+`</summary><pre class="query">`+html.EscapeString(query)+`</pre></details>`+SqlcmdEol, color.TextTypeNormal)
+}
+
+func (f *htmlFormatter) EndBatch() {
+	f.mustWriteOut(`</section>`+SqlcmdEol, color.TextTypeNormal)
+}
+
+// beginDocument writes the document header when the output has moved to a new file
+func (f *htmlFormatter) beginDocument() bool {
+	o := currentOutputFile(f.out)
+	if o == f.document {
+		return false
+	}
+	f.document = o
+	f.mustWriteOut(htmlDocumentHeader, color.TextTypeNormal)
+	return true
+}
+
+func (f *htmlFormatter) BeginResultSet(cols []*sql.ColumnType) {
+	f.BeginColumns(newColumns(cols, f.vars))
+}
+
+func (f *htmlFormatter) BeginColumns(columns []Column) {
+	f.sqlCmdFormatterType.BeginColumns(columns)
+	// Output split by result set starts a new file here
+	f.beginDocument()
+	if f.xml {
+		f.mustWriteOut(`<pre class="`+htmlClasses[color.TextTypeXml]+`">`, color.TextTypeNormal)
+		return
+	}
+	b := new(strings.Builder)
+	b.WriteString("<table>" + SqlcmdEol + "<thead><tr>")
+	for _, c := range f.columnDetails {
+		b.WriteString(`<th class="` + htmlColumnClass(&c.col) + `">` + html.EscapeString(c.col.Name()) + "</th>")
+	}
+	b.WriteString("</tr></thead>" + SqlcmdEol + "<tbody>" + SqlcmdEol)
+	f.mustWriteOut(b.String(), color.TextTypeHeader)
+}
+
+func (f *htmlFormatter) EndResultSet() {
+	if f.xml {
+		f.mustWriteOut("</pre>"+SqlcmdEol, color.TextTypeNormal)
+		return
+	}
+	f.mustWriteOut("</tbody>"+SqlcmdEol+"</table>"+SqlcmdEol, color.TextTypeNormal)
+}
+
+// AddRow writes the current row as a table row
+func (f *htmlFormatter) AddRow(row *sql.Rows) string {
+	return f.addRow(row, f)
+}
+
+// AddValues writes a row as a table row
+func (f *htmlFormatter) AddValues(values []interface{}) {
+	if len(values) == 0 {
+		return
+	}
+	if f.xml {
+		f.mustWriteOut(html.EscapeString(f.formatValue(0, values[0])), color.TextTypeXml)
+	} else {
+		f.writeRow(values)
+	}
+}
+
+// writeRow writes one table row. NULL and binary values get their own classes.
+func (f *htmlFormatter) writeRow(values []interface{}) {
+	b := new(strings.Builder)
+	b.WriteString("<tr>")
+	for i, v := range values {
+		c := &f.columnDetails[i].col
+		class := htmlColumnClass(c)
+		if v == nil {
+			b.WriteString(`<td class="null">` + html.EscapeString(f.vars.NullDisplay()) + `</td>`)
+			continue
+		}
+		val := f.displayValue(i, v)
+		if isNeedingControlCharacterTreatment(c) {
+			val = applyControlCharacterBehavior(val, f.ccb)
+		}
+		if isNeedingHexPrefix(c) {
+			b.WriteString(`<td class="` + class + `"><code>0x` + val + `</code></td>`)
+			continue
+		}
+		b.WriteString(`<td class="` + class + `">` + html.EscapeString(val) + `</td>`)
+	}
+	b.WriteString("</tr>" + SqlcmdEol)
+	f.mustWriteOut(b.String(), color.TextTypeCell)
+	f.rowcount++
+}
+
+func (f *htmlFormatter) AddMessage(msg string) {
+	if !f.xml {
+		f.mustWriteOut(`<p class="`+htmlClasses[color.TextTypeWarning]+`">`+html.EscapeString(msg)+"</p>"+SqlcmdEol, color.TextTypeWarning)
+	}
+}
+
+// AddError writes the error into the document so it is visible in the report.
+// The plain text is also written to the error stream when it is separate from the document.
+func (f *htmlFormatter) AddError(err error) {
+	b, ok := f.errorText(err)
+	if !ok {
+		return
+	}
+	f.mustWriteOut(`<pre class="`+htmlClasses[color.TextTypeError]+`">`+html.EscapeString(strings.TrimSuffix(b.String(), SqlcmdEol))+"</pre>"+SqlcmdEol, color.TextTypeError)
+	if f.err != f.out {
+		f.mustWriteErr(b.String())
+	}
+}
+
+// htmlColumnClass returns the CSS classes for a column, a general category followed by the SQL type
+func htmlColumnClass(c *sql.ColumnType) string {
+	typeName := c.DatabaseTypeName()
+	category := "text"
+	switch {
+	case isNumericType(typeName):
+		category = "num"
+	case isBinaryDataType(c):
+		category = "bin"
+	case typeName == "DATE" || typeName == "TIME" || strings.HasPrefix(typeName, "DATETIME") || typeName == "SMALLDATETIME":
+		category = "date"
+	}
+	if typeName == "" {
+		return category
+	}
+	return category + " type-" + strings.ToLower(typeName)
+}
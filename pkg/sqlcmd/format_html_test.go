@@ -0,0 +1,60 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"strings"
+	"testing"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHtmlFormatterDocument(t *testing.T) {
+	vars := InitializeVariables(false)
+	vars.Set(SQLCMDFORMAT, "html")
+	f := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore).(*htmlFormatter)
+	out := new(strings.Builder)
+
+	f.BeginBatch("select 1 < 2", vars, out, out)
+	f.columnDetails = make([]columnDetail, 3)
+	setColumnInfo(&f.columnDetails[0].col, "id", "INT")
+	setColumnInfo(&f.columnDetails[1].col, "name", "NVARCHAR")
+	setColumnInfo(&f.columnDetails[2].col, "data", "VARBINARY")
+	f.writeRow([]interface{}{int64(1), "<b>&</b>", []byte{0xca, 0xfe}})
+	f.writeRow([]interface{}{int64(2), nil, nil})
+	f.EndResultSet()
+	f.AddMessage("(2 rows affected)")
+	f.AddError(mssql.Error{Number: 208, Class: 16, State: 1, ServerName: "srv", LineNo: 1, Message: "Invalid object name '<t>'."})
+	f.EndBatch()
+	f.BeginBatch("select 2", vars, out, out)
+	f.EndBatch()
+
+	s := out.String()
+	assert.Equal(t, 1, strings.Count(s, "<!DOCTYPE html>"), "the document header is written once per output")
+	assert.Equal(t, 2, strings.Count(s, `<section class="batch">`))
+	assert.Contains(t, s, `<pre class="query">select 1 &lt; 2</pre>`)
+	assert.Contains(t, s, `<tr><td class="num type-int">1</td><td class="text type-nvarchar">&lt;b&gt;&amp;&lt;/b&gt;</td><td class="bin type-varbinary"><code>0xCAFE</code></td></tr>`)
+	assert.Contains(t, s, `<tr><td class="num type-int">2</td><td class="null">NULL</td><td class="null">NULL</td></tr>`)
+	assert.Contains(t, s, `<p class="message">(2 rows affected)</p>`)
+	assert.Contains(t, s, `<pre class="error">Msg 208, Level 16, State 1, Server srv, Line 1`+SqlcmdEol+`Invalid object name &#39;&lt;t&gt;&#39;.</pre>`)
+	assert.Equal(t, 1, strings.Count(s, "Invalid object name"), "errors must not be duplicated when the error stream is the document")
+}
+
+func TestHtmlFormatterXmlModeAndSeparateErrors(t *testing.T) {
+	vars := InitializeVariables(false)
+	f := NewSQLCmdHtmlFormatter(vars, ControlIgnore).(*htmlFormatter)
+	f.XmlMode(true)
+	out, errOut := new(strings.Builder), new(strings.Builder)
+	f.BeginBatch("", vars, out, errOut)
+	f.mustWriteOut(`<pre class="xml">`, 0)
+	f.AddMessage("ignored in XML mode")
+	f.EndResultSet()
+	f.AddError(mssql.Error{Number: 50000, Class: 16, Message: "boom"})
+
+	assert.NotContains(t, out.String(), "ignored in XML mode")
+	assert.Contains(t, out.String(), `<pre class="xml"></pre>`)
+	assert.Contains(t, out.String(), `<pre class="error">`)
+	assert.Contains(t, errOut.String(), "boom")
+}
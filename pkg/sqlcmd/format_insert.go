@@ -0,0 +1,205 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microsoft/go-sqlcmd/internal/color"
+)
+
+// insertRowsPerStatement is the maximum number of row value expressions SQL Server allows in one VALUES clause
+const insertRowsPerStatement = 1000
+
+// insertFormatter writes each result set as T-SQL INSERT statements that recreate its rows.
+// Messages are written as comments so the output stays a runnable script.
+type insertFormatter struct {
+	*sqlCmdFormatterType
+	resultSets    int
+	statementRows int
+	table         string
+	columnList    string
+}
+
+// NewSQLCmdInsertFormatter returns a formatter that scripts result sets as INSERT statements
+func NewSQLCmdInsertFormatter(vars *Variables) Formatter {
+	return &insertFormatter{
+		sqlCmdFormatterType: &sqlCmdFormatterType{
+			format:    "insert",
+			colorizer: color.New(false),
+			vars:      vars,
+		},
+	}
+}
+
+func (f *insertFormatter) BeginResultSet(cols []*sql.ColumnType) {
+	f.BeginColumns(newColumns(cols, f.vars))
+}
+
+func (f *insertFormatter) BeginColumns(columns []Column) {
+	f.sqlCmdFormatterType.BeginColumns(columns)
+	f.startResultSet()
+}
+
+// startResultSet picks the target table and builds the column list for the current result set
+func (f *insertFormatter) startResultSet() {
+	f.resultSets++
+	f.statementRows = 0
+	f.table = f.vars.InsertTableName()
+	if f.table == "" {
+		f.table = quoteName(fmt.Sprintf("ResultSet%d", f.resultSets))
+	}
+	names := make([]string, len(f.columnDetails))
+	for i, c := range f.columnDetails {
+		name := c.col.Name()
+		if name == "" {
+			name = fmt.Sprintf("Column%d", i+1)
+		}
+		names[i] = quoteName(name)
+	}
+	f.columnList = strings.Join(names, ", ")
+}
+
+func (f *insertFormatter) EndResultSet() {
+	if f.xml {
+		f.sqlCmdFormatterType.EndResultSet()
+		return
+	}
+	f.endStatement()
+}
+
+// endStatement terminates the INSERT statement in progress, if any
+func (f *insertFormatter) endStatement() {
+	if f.statementRows > 0 {
+		f.mustWriteOut(";"+SqlcmdEol+SqlcmdEol, color.TextTypeTSql)
+		f.statementRows = 0
+	}
+}
+
+// AddRow writes the current row as an INSERT statement
+func (f *insertFormatter) AddRow(row *sql.Rows) string {
+	return f.addRow(row, f)
+}
+
+// AddValues writes a row as an INSERT statement
+func (f *insertFormatter) AddValues(values []interface{}) {
+	if f.xml {
+		f.sqlCmdFormatterType.AddValues(values)
+		return
+	}
+	f.writeRow(values)
+}
+
+// writeRow adds the row to the current INSERT statement, starting a new statement when needed
+func (f *insertFormatter) writeRow(values []interface{}) {
+	b := new(strings.Builder)
+	if f.statementRows == insertRowsPerStatement {
+		f.endStatement()
+	}
+	if f.statementRows == 0 {
+		b.WriteString("INSERT INTO " + f.table + " (" + f.columnList + ") VALUES" + SqlcmdEol)
+	} else {
+		b.WriteString("," + SqlcmdEol)
+	}
+	b.WriteString("    (")
+	for i, v := range values {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(f.sqlLiteral(i, v))
+	}
+	b.WriteString(")")
+	f.mustWriteOut(b.String(), color.TextTypeTSql)
+	f.statementRows++
+	f.rowcount++
+}
+
+// sqlLiteral returns the T-SQL literal for the driver value of column n
+func (f *insertFormatter) sqlLiteral(n int, v interface{}) string {
+	c := &f.columnDetails[n]
+	typeName := c.col.DatabaseTypeName()
+	switch x := v.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if x {
+			return "1"
+		}
+		return "0"
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'g', -1, 32)
+	case float64:
+		if typeName == "REAL" {
+			return strconv.FormatFloat(x, 'g', -1, 32)
+		}
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	case []byte:
+		switch {
+		case isNumericType(typeName):
+			return string(x)
+		case typeName == "UNIQUEIDENTIFIER":
+			return "'" + decodeUniqueIdentifier(x) + "'"
+		case !isNeedingControlCharacterTreatment(&c.col):
+			// binary columns and UDTs such as geography are inserted from their serialized form
+			return "0x" + decodeBinary(x)
+		}
+		return sqlStringLiteral(string(x), typeName)
+	case time.Time:
+		return "'" + x.Format(isoDateTimeFormatString(typeName, c.scale)) + "'"
+	}
+	return sqlStringLiteral(f.formatValue(n, v), typeName)
+}
+
+// sqlStringLiteral quotes s as a T-SQL string literal, with the N prefix unless the
+// column is known to hold single byte characters.
+func sqlStringLiteral(s string, typeName string) string {
+	prefix := "N'"
+	switch typeName {
+	case "CHAR", "VARCHAR", "TEXT":
+		prefix = "'"
+	}
+	s = strings.ReplaceAll(s, "'", "''")
+	// A backslash immediately before a line break continues the literal on the next line
+	// and is dropped by the parser, so split the literal after such backslashes.
+	s = strings.ReplaceAll(s, "\\\r\n", "\\' + "+prefix+"\r\n")
+	s = strings.ReplaceAll(s, "\\\n", "\\' + "+prefix+"\n")
+	return prefix + s + "'"
+}
+
+// quoteName delimits a T-SQL identifier with brackets
+func quoteName(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+// AddMessage writes the message as a T-SQL comment
+func (f *insertFormatter) AddMessage(msg string) {
+	if !f.xml {
+		f.endStatement()
+		f.mustWriteOut(sqlComment(msg), color.TextTypeWarning)
+	}
+}
+
+// AddError writes errors as comments when they share the output stream with the script
+func (f *insertFormatter) AddError(err error) {
+	if f.err != f.out {
+		f.sqlCmdFormatterType.AddError(err)
+		return
+	}
+	if b, ok := f.errorText(err); ok {
+		f.endStatement()
+		f.mustWriteErr(sqlComment(strings.TrimSuffix(b.String(), SqlcmdEol)))
+	}
+}
+
+// sqlComment prefixes every line of s with the T-SQL line comment marker
+func sqlComment(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	return "-- " + strings.Join(lines, SqlcmdEol+"-- ") + SqlcmdEol
+}
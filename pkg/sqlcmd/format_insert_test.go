@@ -0,0 +1,74 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestInsertFormatter(t *testing.T, vars *Variables, names []string, types []string) (*insertFormatter, *strings.Builder) {
+	t.Helper()
+	vars.Set(SQLCMDFORMAT, "insert")
+	f := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore).(*insertFormatter)
+	out := new(strings.Builder)
+	f.BeginBatch("", vars, out, out)
+	f.columnDetails = make([]columnDetail, len(names))
+	for i := range names {
+		setColumnInfo(&f.columnDetails[i].col, names[i], types[i])
+	}
+	f.startResultSet()
+	return f, out
+}
+
+func TestInsertFormatterLiterals(t *testing.T) {
+	vars := InitializeVariables(false)
+	f, out := newTestInsertFormatter(t, vars,
+		[]string{"id", "name", "code", "price", "ratio", "active", "created", "data", "guid", "]odd", ""},
+		[]string{"INT", "NVARCHAR", "VARCHAR", "DECIMAL", "FLOAT", "BIT", "DATETIME2", "VARBINARY", "UNIQUEIDENTIFIER", "GEOGRAPHY", "NVARCHAR"})
+	f.columnDetails[6].scale = 3
+	created := time.Date(2024, 2, 29, 13, 14, 15, 120000000, time.UTC)
+	guid := []byte{0x67, 0x45, 0x23, 0x01, 0xab, 0x89, 0xef, 0xcd, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+
+	f.writeRow([]interface{}{int64(1), "O'Brien", "abc", []byte("12.50"), 0.5, true, created, []byte{0xde, 0xad}, guid, []byte{0xe6, 0x10}, nil})
+	f.writeRow([]interface{}{int64(2), nil, nil, nil, nil, nil, nil, []byte{}, nil, nil, "x"})
+	f.EndResultSet()
+	f.AddMessage("(2 rows affected)")
+
+	assert.Equal(t, "INSERT INTO [ResultSet1] ([id], [name], [code], [price], [ratio], [active], [created], [data], [guid], []]odd], [Column11]) VALUES"+SqlcmdEol+
+		"    (1, N'O''Brien', 'abc', 12.50, 0.5, 1, '2024-02-29T13:14:15.120', 0xDEAD, '01234567-89ab-cdef-0123-456789abcdef', 0xE610, NULL),"+SqlcmdEol+
+		"    (2, NULL, NULL, NULL, NULL, NULL, NULL, 0x, NULL, NULL, N'x');"+SqlcmdEol+SqlcmdEol+
+		"-- (2 rows affected)"+SqlcmdEol, out.String())
+}
+
+func TestInsertFormatterBatchesRowsAndTableName(t *testing.T) {
+	vars := InitializeVariables(false)
+	vars.Set(SQLCMDINSERTTABLE, "dbo.Colors")
+	f, out := newTestInsertFormatter(t, vars, []string{"id"}, []string{"INT"})
+	for i := 0; i < insertRowsPerStatement+1; i++ {
+		f.writeRow([]interface{}{int64(i)})
+	}
+	f.EndResultSet()
+	assert.Equal(t, 2, strings.Count(out.String(), "INSERT INTO dbo.Colors ([id]) VALUES"), "a VALUES clause is limited to 1000 rows")
+	assert.Equal(t, 2, strings.Count(out.String(), ";"))
+}
+
+func TestSqlStringLiteral(t *testing.T) {
+	assert.Equal(t, "N''", sqlStringLiteral("", "NVARCHAR"))
+	assert.Equal(t, "'it''s'", sqlStringLiteral("it's", "CHAR"))
+	assert.Equal(t, "N'c:\\' + N'\nnext'", sqlStringLiteral("c:\\\nnext", "NTEXT"))
+}
+
+func TestInsertFormatterErrorsAsComments(t *testing.T) {
+	vars := InitializeVariables(false)
+	f, out := newTestInsertFormatter(t, vars, []string{"id"}, []string{"INT"})
+	f.writeRow([]interface{}{int64(1)})
+	f.AddError(mssql.Error{Number: 245, Class: 16, State: 1, ServerName: "srv", LineNo: 1, Message: "Conversion failed."})
+	assert.Equal(t, "INSERT INTO [ResultSet1] ([id]) VALUES"+SqlcmdEol+"    (1);"+SqlcmdEol+SqlcmdEol+
+		"-- Msg 245, Level 16, State 1, Server srv, Line 1"+SqlcmdEol+"-- Conversion failed."+SqlcmdEol, out.String())
+}
@@ -0,0 +1,198 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/microsoft/go-sqlcmd/internal/color"
+	"github.com/microsoft/go-sqlcmd/internal/localizer"
+)
+
+// jsonFormatter writes query output as a stream of JSON values that tools like jq can read directly.
+// Each result set is an array of objects keyed by column name. Messages are written to the output
+// as {"message": ...} objects and errors are written to the error stream as {"error": {...}} objects.
+type jsonFormatter struct {
+	*sqlCmdFormatterType
+}
+
+// NewSQLCmdJsonFormatter returns a formatter that renders result sets as JSON arrays
+func NewSQLCmdJsonFormatter(vars *Variables) Formatter {
+	return &jsonFormatter{
+		sqlCmdFormatterType: &sqlCmdFormatterType{
+			format:    "json",
+			colorizer: color.New(false),
+			vars:      vars,
+		},
+	}
+}
+
+func (f *jsonFormatter) BeginResultSet(cols []*sql.ColumnType) {
+	f.BeginColumns(newColumns(cols, f.vars))
+}
+
+func (f *jsonFormatter) BeginColumns(columns []Column) {
+	f.sqlCmdFormatterType.BeginColumns(columns)
+	if !f.xml {
+		f.mustWriteOut("[", color.TextTypeSeparator)
+	}
+}
+
+func (f *jsonFormatter) EndResultSet() {
+	if f.xml {
+		f.sqlCmdFormatterType.EndResultSet()
+		return
+	}
+	if f.rowcount > 0 {
+		f.mustWriteOut(SqlcmdEol, color.TextTypeNormal)
+	}
+	f.mustWriteOut("]"+SqlcmdEol, color.TextTypeSeparator)
+}
+
+// AddRow writes the current row as a JSON object
+func (f *jsonFormatter) AddRow(row *sql.Rows) string {
+	return f.addRow(row, f)
+}
+
+// AddValues writes a row as a JSON object
+func (f *jsonFormatter) AddValues(values []interface{}) {
+	if f.xml {
+		f.sqlCmdFormatterType.AddValues(values)
+		return
+	}
+	f.writeRow(values)
+}
+
+// writeRow writes one object of the current result set array
+func (f *jsonFormatter) writeRow(values []interface{}) {
+	b := new(bytes.Buffer)
+	if f.rowcount > 0 {
+		b.WriteString(",")
+	}
+	b.WriteString(SqlcmdEol + "  {")
+	for i, v := range values {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		writeJsonString(b, f.columnDetails[i].col.Name())
+		b.WriteString(":")
+		f.writeJsonValue(b, i, v)
+	}
+	b.WriteString("}")
+	f.rowcount++
+	f.mustWriteOut(b.String(), color.TextTypeCell)
+}
+
+// writeJsonValue writes the driver value of column n as a typed JSON value.
+// Numbers and bits become JSON numbers and booleans, NULL becomes null,
+// and date and time values are written as ISO 8601 strings.
+func (f *jsonFormatter) writeJsonValue(b *bytes.Buffer, n int, v interface{}) {
+	c := &f.columnDetails[n]
+	switch x := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(x))
+	case int64:
+		b.WriteString(strconv.FormatInt(x, 10))
+	case float64:
+		b.WriteString(strconv.FormatFloat(x, 'g', -1, 64))
+	case []byte:
+		switch {
+		case isBinaryDataType(&c.col):
+			writeJsonString(b, "0x"+decodeBinary(x))
+		case isNumericType(c.col.DatabaseTypeName()):
+			// DECIMAL and MONEY values arrive as their exact text representation
+			b.Write(x)
+		default:
+			writeJsonString(b, f.formatValue(n, x))
+		}
+	case time.Time:
+		writeJsonString(b, x.Format(isoDateTimeFormatString(c.col.DatabaseTypeName(), c.scale)))
+	default:
+		writeJsonString(b, f.formatValue(n, x))
+	}
+}
+
+// isoDateTimeFormatString returns the ISO 8601 layout for the given date or time type
+func isoDateTimeFormatString(typeName string, scale int) string {
+	fraction := ""
+	switch typeName {
+	case "DATETIME":
+		scale = 3
+	case "SMALLDATETIME":
+		scale = 0
+	}
+	if scale > 0 {
+		fraction = "." + strings.Repeat("0", scale)
+	}
+	switch typeName {
+	case "DATE":
+		return "2006-01-02"
+	case "TIME":
+		return "15:04:05" + fraction
+	case "DATETIMEOFFSET":
+		return "2006-01-02T15:04:05" + fraction + "Z07:00"
+	case "DATETIME", "DATETIME2", "SMALLDATETIME":
+		return "2006-01-02T15:04:05" + fraction
+	}
+	return time.RFC3339Nano
+}
+
+// AddMessage writes the message as a {"message": ...} object
+func (f *jsonFormatter) AddMessage(msg string) {
+	if f.xml {
+		return
+	}
+	b := new(bytes.Buffer)
+	b.WriteString(`{"message":`)
+	writeJsonString(b, msg)
+	b.WriteString("}" + SqlcmdEol)
+	f.mustWriteOut(b.String(), color.TextTypeWarning)
+}
+
+// AddError writes the error as an {"error": {...}} object to the error stream
+func (f *jsonFormatter) AddError(err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = localizer.Errorf("Timeout expired")
+	}
+	b := new(bytes.Buffer)
+	b.WriteString(`{"error":{`)
+	var e mssql.Error
+	if errors.As(err, &e) {
+		if f.vars.ErrorLevel() > 0 && e.Class < uint8(f.vars.ErrorLevel()) {
+			return
+		}
+		fmt.Fprintf(b, `"number":%d,"severity":%d,"state":%d,"server":`, e.Number, e.Class, e.State)
+		writeJsonString(b, e.ServerName)
+		b.WriteString(`,"procedure":`)
+		writeJsonString(b, e.ProcName)
+		fmt.Fprintf(b, `,"line":%d,"message":`, e.LineNo)
+		writeJsonString(b, e.Message)
+	} else {
+		b.WriteString(`"message":`)
+		writeJsonString(b, err.Error())
+	}
+	b.WriteString("}}" + SqlcmdEol)
+	f.mustWriteErr(b.String())
+}
+
+// writeJsonString writes s as a quoted JSON string without escaping HTML characters
+func writeJsonString(b *bytes.Buffer, s string) {
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	// Encoding a string cannot fail
+	_ = enc.Encode(s)
+	// Encode appends a newline
+	b.Truncate(b.Len() - 1)
+}
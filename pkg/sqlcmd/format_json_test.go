@@ -0,0 +1,111 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/microsoft/go-sqlcmd/internal/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestJsonFormatter(t *testing.T, names []string, types []string) (*jsonFormatter, *strings.Builder, *strings.Builder) {
+	t.Helper()
+	vars := InitializeVariables(false)
+	vars.Set(SQLCMDFORMAT, "json")
+	f := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore).(*jsonFormatter)
+	out, errOut := new(strings.Builder), new(strings.Builder)
+	f.BeginBatch("", vars, out, errOut)
+	f.columnDetails = make([]columnDetail, len(names))
+	for i := range names {
+		setColumnInfo(&f.columnDetails[i].col, names[i], types[i])
+	}
+	return f, out, errOut
+}
+
+func TestJsonFormatterTypedValues(t *testing.T) {
+	f, out, _ := newTestJsonFormatter(t,
+		[]string{"id", "name", "price", "active", "ratio", "created", "born", "data", "missing"},
+		[]string{"INT", "NVARCHAR", "DECIMAL", "BIT", "FLOAT", "DATETIME2", "DATE", "VARBINARY", "NVARCHAR"})
+	f.columnDetails[5].scale = 3
+	created := time.Date(2024, 2, 29, 13, 14, 15, 123000000, time.UTC)
+	born := time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	f.mustWriteOut("[", color.TextTypeNormal)
+	f.writeRow([]interface{}{int64(1), `a "quoted" <name>`, []byte("12.50"), true, 0.25, created, born, []byte{0xde, 0xad}, nil})
+	f.writeRow([]interface{}{int64(2), "b", []byte("-3.00"), false, float64(1e20), created, born, []byte{}, nil})
+	f.EndResultSet()
+
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(out.String()), &rows), "output must be valid JSON: %s", out.String())
+	require.Len(t, rows, 2)
+	assert.Equal(t, float64(1), rows[0]["id"])
+	assert.Equal(t, `a "quoted" <name>`, rows[0]["name"])
+	assert.Equal(t, 12.5, rows[0]["price"])
+	assert.Equal(t, true, rows[0]["active"])
+	assert.Equal(t, 0.25, rows[0]["ratio"])
+	assert.Equal(t, "2024-02-29T13:14:15.123", rows[0]["created"])
+	assert.Equal(t, "1999-12-31", rows[0]["born"])
+	assert.Equal(t, "0xDEAD", rows[0]["data"])
+	assert.Contains(t, rows[0], "missing")
+	assert.Nil(t, rows[0]["missing"])
+	assert.Equal(t, false, rows[1]["active"])
+	assert.Contains(t, out.String(), `"name":"a \"quoted\" <name>"`, "HTML characters should not be escaped")
+}
+
+func TestJsonFormatterIgnoresDisplayVariables(t *testing.T) {
+	f, out, _ := newTestJsonFormatter(t, []string{"id", "created", "missing"}, []string{"DECIMAL", "DATETIME2", "NVARCHAR"})
+	f.vars.Set(SQLCMDNULLDISPLAY, "(null)")
+	f.vars.Set(SQLCMDDATETIMEFORMAT, "epoch")
+	f.vars.Set(SQLCMDNUMBERFORMAT, "#,##0.00")
+	created := time.Date(2024, 2, 29, 13, 14, 15, 0, time.UTC)
+
+	f.mustWriteOut("[", color.TextTypeNormal)
+	f.writeRow([]interface{}{[]byte("1234.5"), created, nil})
+	f.EndResultSet()
+	assert.Equal(t, `[`+SqlcmdEol+`  {"id":1234.5,"created":"2024-02-29T13:14:15","missing":null}`+SqlcmdEol+`]`+SqlcmdEol, out.String())
+}
+
+func TestJsonFormatterEmptyResultSet(t *testing.T) {
+	f, out, _ := newTestJsonFormatter(t, []string{"id"}, []string{"INT"})
+	f.mustWriteOut("[", color.TextTypeNormal)
+	f.EndResultSet()
+	assert.Equal(t, "[]"+SqlcmdEol, out.String())
+}
+
+func TestJsonFormatterMessagesAndErrors(t *testing.T) {
+	f, out, errOut := newTestJsonFormatter(t, nil, nil)
+	f.AddMessage("(3 rows affected)")
+	f.AddError(mssql.Error{Number: 208, State: 1, Class: 16, Message: "Invalid object name 'nope'.", ServerName: "server", ProcName: "proc", LineNo: 4})
+
+	var msg map[string]string
+	require.NoError(t, json.Unmarshal([]byte(out.String()), &msg))
+	assert.Equal(t, "(3 rows affected)", msg["message"])
+
+	var e map[string]map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(errOut.String()), &e))
+	assert.Equal(t, map[string]interface{}{
+		"number":    float64(208),
+		"severity":  float64(16),
+		"state":     float64(1),
+		"server":    "server",
+		"procedure": "proc",
+		"line":      float64(4),
+		"message":   "Invalid object name 'nope'.",
+	}, e["error"])
+}
+
+func TestIsoDateTimeFormatString(t *testing.T) {
+	ts := time.Date(2022, 3, 5, 14, 1, 2, 123456700, time.FixedZone("", -5*3600))
+	assert.Equal(t, "2022-03-05T14:01:02.123", ts.Format(isoDateTimeFormatString("DATETIME", 7)))
+	assert.Equal(t, "2022-03-05T14:01:02", ts.Format(isoDateTimeFormatString("SMALLDATETIME", 7)))
+	assert.Equal(t, "2022-03-05T14:01:02.1234567-05:00", ts.Format(isoDateTimeFormatString("DATETIMEOFFSET", 7)))
+	assert.Equal(t, "14:01:02.12", ts.Format(isoDateTimeFormatString("TIME", 2)))
+	assert.Equal(t, "2022-03-05", ts.Format(isoDateTimeFormatString("DATE", 0)))
+}
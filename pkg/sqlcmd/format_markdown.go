@@ -0,0 +1,142 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/microsoft/go-sqlcmd/internal/color"
+)
+
+// markdownFormatter renders each result set as a GitHub-flavored Markdown table.
+// Messages are rendered as blockquotes.
+type markdownFormatter struct {
+	*sqlCmdFormatterType
+	rows      [][]string
+	colWidths []int
+}
+
+// NewSQLCmdMarkdownFormatter returns a formatter that renders result sets as Markdown tables
+func NewSQLCmdMarkdownFormatter(vars *Variables, ccb ControlCharacterBehavior) Formatter {
+	return &markdownFormatter{
+		sqlCmdFormatterType: &sqlCmdFormatterType{
+			format:    "markdown",
+			colorizer: color.New(false),
+			ccb:       ccb,
+			vars:      vars,
+		},
+	}
+}
+
+func (f *markdownFormatter) BeginResultSet(cols []*sql.ColumnType) {
+	f.BeginColumns(newColumns(cols, f.vars))
+}
+
+func (f *markdownFormatter) BeginColumns(columns []Column) {
+	f.sqlCmdFormatterType.BeginColumns(columns)
+	f.rows = make([][]string, 0)
+	f.colWidths = make([]int, len(f.columnDetails))
+	for i, c := range f.columnDetails {
+		// Delimiter cells need at least 3 dashes
+		f.colWidths[i] = max(3, stringWidth(markdownEscape(c.col.Name())))
+	}
+}
+
+// AddRow writes the current row as a table row
+func (f *markdownFormatter) AddRow(row *sql.Rows) string {
+	return f.addRow(row, f)
+}
+
+// AddValues writes a row as a table row
+func (f *markdownFormatter) AddValues(values []interface{}) {
+	if f.xml {
+		f.sqlCmdFormatterType.AddValues(values)
+		return
+	}
+	cells := make([]string, len(values))
+	for i, v := range values {
+		val := f.displayValue(i, v)
+		c := &f.columnDetails[i].col
+		if v != nil && isNeedingControlCharacterTreatment(c) {
+			val = applyControlCharacterBehavior(val, f.ccb)
+		}
+		if _, file := v.(blobFile); v != nil && !file && isNeedingHexPrefix(c) {
+			val = "0x" + val
+		}
+		cells[i] = val
+	}
+	f.addValues(cells)
+}
+
+// addValues escapes the values of one row and stores them until the end of the result set
+func (f *markdownFormatter) addValues(values []string) {
+	cells := make([]string, len(values))
+	for i, v := range values {
+		cells[i] = markdownEscape(v)
+		if l := stringWidth(cells[i]); l > f.colWidths[i] {
+			f.colWidths[i] = l
+		}
+	}
+	f.rows = append(f.rows, cells)
+	f.rowcount++
+}
+
+func (f *markdownFormatter) EndResultSet() {
+	if f.xml {
+		f.sqlCmdFormatterType.EndResultSet()
+		return
+	}
+	if len(f.columnDetails) > 0 {
+		f.printMarkdownTable()
+	}
+	f.rows = nil
+	f.colWidths = nil
+}
+
+func (f *markdownFormatter) printMarkdownTable() {
+	header := new(strings.Builder)
+	divider := new(strings.Builder)
+	header.WriteString("|")
+	divider.WriteString("|")
+	for i, c := range f.columnDetails {
+		header.WriteString(" " + padRightString(markdownEscape(c.col.Name()), f.colWidths[i]) + " |")
+		if isNumericType(c.col.DatabaseTypeName()) {
+			divider.WriteString(" " + strings.Repeat("-", f.colWidths[i]-1) + ": |")
+		} else {
+			divider.WriteString(" " + strings.Repeat("-", f.colWidths[i]) + " |")
+		}
+	}
+	f.mustWriteOut(header.String()+SqlcmdEol, color.TextTypeHeader)
+	f.mustWriteOut(divider.String()+SqlcmdEol, color.TextTypeSeparator)
+	for _, row := range f.rows {
+		line := new(strings.Builder)
+		line.WriteString("|")
+		for i, cell := range row {
+			if isNumericType(f.columnDetails[i].col.DatabaseTypeName()) {
+				line.WriteString(" " + padLeftString(cell, f.colWidths[i]) + " |")
+			} else {
+				line.WriteString(" " + padRightString(cell, f.colWidths[i]) + " |")
+			}
+		}
+		f.mustWriteOut(line.String()+SqlcmdEol, color.TextTypeCell)
+	}
+	f.mustWriteOut(SqlcmdEol, color.TextTypeNormal)
+}
+
+// AddMessage writes the message as a blockquote
+func (f *markdownFormatter) AddMessage(msg string) {
+	if !f.xml {
+		f.mustWriteOut("> "+markdownEscape(msg)+SqlcmdEol+SqlcmdEol, color.TextTypeWarning)
+	}
+}
+
+// markdownEscape makes s safe to use inside a table cell. Pipes are escaped and
+// line breaks are replaced with <br> since a table row can't span lines.
+// Other characters, backslashes included, are written as they are.
+func markdownEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	s = strings.ReplaceAll(s, "\r\n", "<br>")
+	return strings.NewReplacer("\n", "<br>", "\r", "<br>").Replace(s)
+}
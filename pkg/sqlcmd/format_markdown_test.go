@@ -0,0 +1,65 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdownEscape(t *testing.T) {
+	assert.Equal(t, `a\|b`, markdownEscape("a|b"))
+	assert.Equal(t, `c:\temp`, markdownEscape(`c:\temp`))
+	assert.Equal(t, "one<br>two<br>three<br>four", markdownEscape("one\r\ntwo\nthree\rfour"))
+}
+
+func TestMarkdownFormatterTable(t *testing.T) {
+	vars := InitializeVariables(false)
+	vars.Set(SQLCMDFORMAT, "markdown")
+	f := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore).(*markdownFormatter)
+	out := new(strings.Builder)
+	f.BeginBatch("", vars, out, out)
+	f.columnDetails = make([]columnDetail, 3)
+	setColumnInfo(&f.columnDetails[0].col, "id", "INT")
+	setColumnInfo(&f.columnDetails[1].col, "name", "NVARCHAR")
+	setColumnInfo(&f.columnDetails[2].col, "", "DECIMAL")
+	f.colWidths = []int{3, 4, 3}
+
+	f.addValues([]string{"1", "pipe|name", "12.50"})
+	f.addValues([]string{"1000", "multi\nline", "NULL"})
+	f.EndResultSet()
+	f.AddMessage("(2 rows affected)")
+
+	lines := strings.Split(out.String(), SqlcmdEol)
+	assert.Equal(t, []string{
+		"| id   | name          |       |",
+		"| ---: | ------------- | ----: |",
+		"|    1 | pipe\\|name    | 12.50 |",
+		"| 1000 | multi<br>line |  NULL |",
+		"",
+		"> (2 rows affected)",
+		"",
+		"",
+	}, lines)
+}
+
+func TestMarkdownFormatterBinaryValues(t *testing.T) {
+	vars := InitializeVariables(false)
+	vars.Set(SQLCMDFORMAT, "markdown")
+	vars.Set(SQLCMDNULLDISPLAY, "AB")
+	f := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore).(*markdownFormatter)
+	out := new(strings.Builder)
+	f.BeginBatch("", vars, out, out)
+	f.columnDetails = make([]columnDetail, 1)
+	setColumnInfo(&f.columnDetails[0].col, "data", "VARBINARY")
+	f.colWidths = []int{4}
+
+	f.AddValues([]interface{}{[]byte{0xab}})
+	f.AddValues([]interface{}{nil})
+	f.EndResultSet()
+	assert.Equal(t, []string{"| data |", "| ---- |", "| 0xAB |", "| AB   |", "", ""}, strings.Split(out.String(), SqlcmdEol),
+		"binary values get the 0x prefix even when they match the NULL text")
+}
@@ -0,0 +1,341 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/go-sqlcmd/internal/color"
+	"github.com/microsoft/go-sqlcmd/internal/localizer"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/snappy"
+)
+
+// parquetFormatter writes each result set as an Apache Parquet file.
+// The first result set written to an output goes to the output itself, later ones
+// go to numbered files next to it. Messages are written to the error stream since the output is binary.
+// The console isn't a valid output.
+// XML mode has no effect; XML results are written as string columns.
+type parquetFormatter struct {
+	*sqlCmdFormatterType
+	// output is the file the result set numbering applies to
+	output     outputFile
+	resultSets int
+	writer     *parquet.Writer
+	file       *os.File
+	columns    []parquetColumn
+}
+
+// parquetColumn describes how the values of one result set column are stored
+type parquetColumn struct {
+	node    parquet.Node
+	name    string
+	convert func(v interface{}) (parquet.Value, error)
+}
+
+// NewSQLCmdParquetFormatter returns a formatter that writes result sets as Parquet files
+func NewSQLCmdParquetFormatter(vars *Variables) Formatter {
+	return &parquetFormatter{
+		sqlCmdFormatterType: &sqlCmdFormatterType{
+			format:    "parquet",
+			colorizer: color.New(false),
+			vars:      vars,
+		},
+	}
+}
+
+// BeginBatch sends errors to stderr when they would otherwise be mixed into the binary output
+func (f *parquetFormatter) BeginBatch(query string, vars *Variables, out io.Writer, err io.Writer) {
+	f.sqlCmdFormatterType.BeginBatch(query, vars, out, err)
+	if err == out {
+		f.err = os.Stderr
+	}
+}
+
+func (f *parquetFormatter) BeginResultSet(cols []*sql.ColumnType) {
+	f.BeginColumns(newColumns(cols, f.vars))
+}
+
+func (f *parquetFormatter) BeginColumns(columns []Column) {
+	f.sqlCmdFormatterType.BeginColumns(columns)
+	if err := requireOutputFile(f.out, "parquet"); err != nil {
+		f.AddError(err)
+		return
+	}
+	if o := currentOutputFile(f.out); o != f.output {
+		f.output = o
+		f.resultSets = 0
+	}
+	f.resultSets++
+	f.columns = f.parquetColumns()
+	w := f.out
+	if f.resultSets > 1 {
+		name, err := numberedFileName(f.out, f.resultSets)
+		if err == nil {
+			f.file, err = os.Create(name)
+		}
+		if err != nil {
+			f.AddError(err)
+			return
+		}
+		w = f.file
+	}
+	f.writer = parquet.NewWriter(w, parquet.NewSchema("resultset", parquetGroup{columns: f.columns}), parquet.Compression(&snappy.Codec{}))
+}
+
+func (f *parquetFormatter) EndResultSet() {
+	if f.writer != nil {
+		if err := f.writer.Close(); err != nil {
+			f.AddError(err)
+		}
+		f.writer = nil
+	}
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			f.AddError(err)
+		}
+		f.file = nil
+	}
+}
+
+// AddRow writes the current row to the Parquet file
+func (f *parquetFormatter) AddRow(row *sql.Rows) string {
+	return f.addRow(row, f)
+}
+
+// AddValues writes a row to the Parquet file
+func (f *parquetFormatter) AddValues(values []interface{}) {
+	if f.writer != nil {
+		if err := f.writeRow(values); err != nil {
+			f.AddError(err)
+		}
+	}
+}
+
+// writeRow converts the driver values to Parquet values and writes them as one row
+func (f *parquetFormatter) writeRow(values []interface{}) error {
+	row := make(parquet.Row, len(values))
+	for i, v := range values {
+		if v == nil {
+			row[i] = parquet.NullValue().Level(0, 0, i)
+			continue
+		}
+		if file, ok := v.(blobFile); ok {
+			// the name of a file written by :BLOBOUT replaces the value
+			row[i] = parquet.ByteArrayValue([]byte(file)).Level(0, 1, i)
+			continue
+		}
+		pv, err := f.columns[i].convert(v)
+		if err != nil {
+			return localizer.Errorf("Unable to convert the value of column %s: %s", f.columns[i].name, err.Error())
+		}
+		row[i] = pv.Level(0, 1, i)
+	}
+	_, err := f.writer.WriteRows([]parquet.Row{row})
+	f.rowcount++
+	return err
+}
+
+// AddMessage writes the message to the error stream since it can't be part of a Parquet file
+func (f *parquetFormatter) AddMessage(msg string) {
+	f.mustWriteMessage(msg)
+}
+
+// parquetColumns builds the schema of the current result set. Empty and duplicate column
+// names are replaced since Parquet requires unique field names.
+func (f *parquetFormatter) parquetColumns() []parquetColumn {
+	columns := make([]parquetColumn, len(f.columnDetails))
+	used := make(map[string]bool)
+	for i, c := range f.columnDetails {
+		base := c.col.Name()
+		if base == "" {
+			base = fmt.Sprintf("Column%d", i+1)
+		}
+		name := base
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		used[name] = true
+		columns[i] = newParquetColumn(name, &c)
+		if columns[i].convert == nil {
+			n := i
+			columns[i].convert = func(v interface{}) (parquet.Value, error) {
+				return parquet.ByteArrayValue([]byte(f.formatValue(n, v))), nil
+			}
+		}
+	}
+	return columns
+}
+
+// newParquetColumn maps the SQL type of the column to a Parquet type. String columns and
+// types without a Parquet equivalent are left without a converter and are stored as text.
+func newParquetColumn(name string, c *columnDetail) parquetColumn {
+	p := parquetColumn{name: name}
+	switch c.col.DatabaseTypeName() {
+	case "BIT":
+		p.node = parquet.Leaf(parquet.BooleanType)
+		p.convert = parquetConverter(parquet.BooleanValue)
+	case "TINYINT":
+		p.node = parquet.Uint(8)
+		p.convert = parquetConverter(func(i int64) parquet.Value { return parquet.Int32Value(int32(i)) })
+	case "SMALLINT":
+		p.node = parquet.Int(16)
+		p.convert = parquetConverter(func(i int64) parquet.Value { return parquet.Int32Value(int32(i)) })
+	case "INT":
+		p.node = parquet.Int(32)
+		p.convert = parquetConverter(func(i int64) parquet.Value { return parquet.Int32Value(int32(i)) })
+	case "BIGINT":
+		p.node = parquet.Int(64)
+		p.convert = parquetConverter(parquet.Int64Value)
+	case "REAL":
+		p.node = parquet.Leaf(parquet.FloatType)
+		p.convert = parquetConverter(func(f float64) parquet.Value { return parquet.FloatValue(float32(f)) })
+	case "FLOAT":
+		p.node = parquet.Leaf(parquet.DoubleType)
+		p.convert = parquetConverter(parquet.DoubleValue)
+	case "MONEY":
+		p.node, p.convert = parquetDecimal(19, 4)
+	case "SMALLMONEY":
+		p.node, p.convert = parquetDecimal(10, 4)
+	case "DECIMAL", "NUMERIC":
+		p.node, p.convert = parquetDecimal(c.precision, c.scale)
+	case "DATE":
+		p.node = parquet.Date()
+		p.convert = parquetConverter(func(t time.Time) parquet.Value {
+			midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			return parquet.Int32Value(int32(midnight.Unix() / 86400))
+		})
+	case "TIME":
+		p.node = parquet.TimeAdjusted(parquet.Nanosecond, false)
+		p.convert = parquetConverter(func(t time.Time) parquet.Value {
+			return parquet.Int64Value(int64(t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()))))
+		})
+	case "DATETIME", "DATETIME2", "SMALLDATETIME":
+		// Microseconds cover the whole datetime2 range, nanoseconds overflow before year 1678.
+		// The values have no time zone so they are stored as local timestamps.
+		p.node = parquet.TimestampAdjusted(parquet.Microsecond, false)
+		p.convert = parquetConverter(func(t time.Time) parquet.Value {
+			wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+			return parquet.Int64Value(wall.UnixMicro())
+		})
+	case "DATETIMEOFFSET":
+		p.node = parquet.TimestampAdjusted(parquet.Microsecond, true)
+		p.convert = parquetConverter(func(t time.Time) parquet.Value { return parquet.Int64Value(t.UnixMicro()) })
+	case "UNIQUEIDENTIFIER":
+		p.node = parquet.UUID()
+		p.convert = parquetConverter(func(b []byte) parquet.Value {
+			u := uuid.MustParse(decodeUniqueIdentifier(b))
+			return parquet.FixedLenByteArrayValue(u[:])
+		})
+	case "CHAR", "VARCHAR", "TEXT", "NCHAR", "NVARCHAR", "NTEXT", "XML":
+		p.node = parquet.String()
+	default:
+		if t := c.col.ScanType(); isBinaryDataType(&c.col) || t != nil && t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// binary, rowversion and CLR types such as geography keep their serialized form
+			p.node = parquet.Leaf(parquet.ByteArrayType)
+			p.convert = parquetConverter(parquet.ByteArrayValue)
+		} else {
+			p.node = parquet.String()
+		}
+	}
+	p.node = parquet.Optional(p.node)
+	return p
+}
+
+// parquetConverter wraps fn in a converter that checks the type of the driver value
+func parquetConverter[T any](fn func(T) parquet.Value) func(v interface{}) (parquet.Value, error) {
+	return func(v interface{}) (parquet.Value, error) {
+		x, ok := v.(T)
+		if !ok {
+			return parquet.Value{}, localizer.Errorf("unexpected value of type %T", v)
+		}
+		return fn(x), nil
+	}
+}
+
+// parquetDecimal returns the node and converter for a decimal with the given precision and scale,
+// using the smallest physical type that can hold it
+func parquetDecimal(precision int, scale int) (parquet.Node, func(v interface{}) (parquet.Value, error)) {
+	if precision < 1 {
+		precision = 38
+	}
+	pow := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil))
+	unscaled := func(v interface{}) (*big.Int, error) {
+		b, ok := v.([]byte)
+		if !ok {
+			return nil, localizer.Errorf("unexpected value of type %T", v)
+		}
+		r, ok := new(big.Rat).SetString(string(b))
+		if !ok {
+			return nil, localizer.Errorf("invalid decimal value %s", string(b))
+		}
+		r.Mul(r, pow)
+		return new(big.Int).Quo(r.Num(), r.Denom()), nil
+	}
+	var node parquet.Node
+	var value func(i *big.Int) parquet.Value
+	switch {
+	case precision <= 9:
+		node = parquet.Decimal(scale, precision, parquet.Int32Type)
+		value = func(i *big.Int) parquet.Value { return parquet.Int32Value(int32(i.Int64())) }
+	case precision <= 18:
+		node = parquet.Decimal(scale, precision, parquet.Int64Type)
+		value = func(i *big.Int) parquet.Value { return parquet.Int64Value(i.Int64()) }
+	default:
+		node = parquet.Decimal(scale, precision, parquet.FixedLenByteArrayType(16))
+		value = func(i *big.Int) parquet.Value {
+			// big endian two's complement
+			if i.Sign() < 0 {
+				i.Add(i, new(big.Int).Lsh(big.NewInt(1), 128))
+			}
+			return parquet.FixedLenByteArrayValue(i.FillBytes(make([]byte, 16)))
+		}
+	}
+	return node, func(v interface{}) (parquet.Value, error) {
+		i, err := unscaled(v)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return value(i), nil
+	}
+}
+
+// parquetGroup is the root node of a result set schema. Unlike parquet.Group it keeps
+// the columns in the order of the result set.
+type parquetGroup struct {
+	parquet.Group
+	columns []parquetColumn
+}
+
+func (g parquetGroup) Fields() []parquet.Field {
+	fields := make([]parquet.Field, len(g.columns))
+	for i := range g.columns {
+		fields[i] = parquetField{Node: g.columns[i].node, name: g.columns[i].name}
+	}
+	return fields
+}
+
+func (g parquetGroup) GoType() reflect.Type {
+	return reflect.TypeOf(map[string]interface{}{})
+}
+
+// parquetField names a column node within a parquetGroup
+type parquetField struct {
+	parquet.Node
+	name string
+}
+
+func (f parquetField) Name() string { return f.name }
+
+// Value is only used when writing Go values. The formatter writes rows of parquet.Value instead.
+func (f parquetField) Value(base reflect.Value) reflect.Value {
+	return base.MapIndex(reflect.ValueOf(f.name))
+}
@@ -0,0 +1,117 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParquetFormatterWritesTypedColumns(t *testing.T) {
+	vars := InitializeVariables(false)
+	vars.Set(SQLCMDFORMAT, "parquet")
+	f := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore).(*parquetFormatter)
+	name := filepath.Join(t.TempDir(), "results.parquet")
+	out, err := os.Create(name)
+	require.NoError(t, err)
+	f.BeginBatch("", vars, out, out)
+
+	names := []string{"id", "name", "price", "big", "created", "born", "guid", "data", ""}
+	types := []string{"INT", "NVARCHAR", "DECIMAL", "DECIMAL", "DATETIME2", "DATE", "UNIQUEIDENTIFIER", "VARBINARY", "SQL_VARIANT"}
+	f.columnDetails = make([]columnDetail, len(names))
+	for i := range names {
+		setColumnInfo(&f.columnDetails[i].col, names[i], types[i])
+	}
+	f.columnDetails[2].precision, f.columnDetails[2].scale = 9, 2
+	f.columnDetails[3].precision, f.columnDetails[3].scale = 38, 4
+	f.resultSets = 1
+	f.columns = f.parquetColumns()
+	f.writer = parquet.NewWriter(out, parquet.NewSchema("resultset", parquetGroup{columns: f.columns}))
+
+	created := time.Date(1601, 2, 3, 4, 5, 6, 789012000, time.UTC)
+	born := time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)
+	guid := []byte{0x67, 0x45, 0x23, 0x01, 0xab, 0x89, 0xef, 0xcd, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+	require.NoError(t, f.writeRow([]interface{}{int64(7), "seven", []byte("-12.50"), []byte("-123456789012345678901234.5678"), created, born, guid, []byte{0xca, 0xfe}, int64(3)}))
+	require.NoError(t, f.writeRow([]interface{}{nil, nil, nil, nil, nil, nil, nil, nil, nil}))
+	assert.Error(t, f.writeRow([]interface{}{"not an int", nil, nil, nil, nil, nil, nil, nil, nil}), "unexpected driver types must be reported")
+	f.EndResultSet()
+	require.NoError(t, out.Close())
+
+	file, err := os.Open(name)
+	require.NoError(t, err)
+	defer file.Close()
+	stat, err := file.Stat()
+	require.NoError(t, err)
+	pf, err := parquet.OpenFile(file, stat.Size())
+	require.NoError(t, err)
+
+	fields := pf.Schema().Fields()
+	actualNames := make([]string, len(fields))
+	for i, field := range fields {
+		actualNames[i] = field.Name()
+	}
+	assert.Equal(t, []string{"id", "name", "price", "big", "created", "born", "guid", "data", "Column9"}, actualNames, "columns keep the result set order")
+	assert.Equal(t, "DECIMAL(9,2)", fields[2].Type().String())
+	assert.Equal(t, "UUID", fields[6].Type().String())
+	assert.Equal(t, int64(2), pf.NumRows())
+
+	rows := make([]parquet.Row, 2)
+	r := parquet.NewReader(file)
+	n, _ := r.ReadRows(rows)
+	require.Equal(t, 2, n)
+	row := rows[0]
+	assert.Equal(t, int32(7), row[0].Int32())
+	assert.Equal(t, "seven", row[1].String())
+	assert.Equal(t, int32(-1250), row[2].Int32())
+	expected, _ := new(big.Int).SetString("-1234567890123456789012345678", 10)
+	expected.Add(expected, new(big.Int).Lsh(big.NewInt(1), 128))
+	assert.Equal(t, expected.FillBytes(make([]byte, 16)), row[3].ByteArray())
+	assert.Equal(t, created.UnixMicro(), row[4].Int64())
+	assert.Equal(t, int32(-1), row[5].Int32())
+	assert.Equal(t, []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}, row[6].ByteArray())
+	assert.Equal(t, []byte{0xca, 0xfe}, row[7].ByteArray())
+	assert.Equal(t, "3", row[8].String())
+	for i, v := range rows[1] {
+		assert.Truef(t, v.IsNull(), "column %d of the second row should be null", i)
+	}
+}
+
+func TestParquetFormatterRequiresOutputFile(t *testing.T) {
+	vars := InitializeVariables(false)
+	f := NewSQLCmdParquetFormatter(vars).(*parquetFormatter)
+	errOut := new(strings.Builder)
+	f.BeginBatch("", vars, os.Stdout, errOut)
+	f.BeginResultSet(nil)
+	assert.Nil(t, f.writer, "no Parquet data is written to the console")
+	f.AddMessage("(1 row affected)")
+	f.EndResultSet()
+	f.EndBatch()
+	assert.Equal(t, "Results were not written. The parquet format requires an output file name. Use :OUT or -o to set one"+SqlcmdEol+"(1 row affected)"+SqlcmdEol, errOut.String())
+}
+
+func TestOutCommandSelectsParquetFormatter(t *testing.T) {
+	vars := InitializeVariables(false)
+	s := New(nil, "", vars)
+	text := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore)
+	s.Format = text
+	defer s.SetOutput(nil)
+
+	err := outCommand(s, []string{filepath.Join(t.TempDir(), "results.PARQUET")}, 1)
+	assert.NoError(t, err, "outCommand")
+	assert.IsType(t, &parquetFormatter{}, s.Format, ":OUT to a .parquet file uses the parquet formatter")
+	s.Format.XmlMode(true)
+
+	err = outCommand(s, []string{"stdout"}, 1)
+	assert.NoError(t, err, "outCommand")
+	assert.Same(t, text, s.Format, ":OUT to another destination restores the formatter")
+	assert.True(t, s.Format.IsXmlMode(), "XML mode is preserved across formatters")
+}
@@ -5,12 +5,20 @@ package sqlcmd
 
 import (
 	"context"
+	"database/sql"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+	"unsafe"
 
 	mssql "github.com/microsoft/go-mssqldb"
 	"github.com/microsoft/go-sqlcmd/internal/color"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFitToScreen(t *testing.T) {
@@ -164,6 +172,17 @@ func TestFormatterXmlMode(t *testing.T) {
 	assert.Equal(t, `<sys.databases name="master"/>`+SqlcmdEol, buf.buf.String())
 }
 
+func TestFormatterXmlIndent(t *testing.T) {
+	s, buf := setupSqlCmdWithMemoryOutput(t)
+	defer buf.Close()
+	s.Format.XmlMode(true)
+	setXmlIndent(s.Format, true)
+	err := runSqlCmd(t, s, []string{"select 1 as a, 2 as b for xml path('row'), root('rows')", "GO"})
+	assert.NoError(t, err, "runSqlCmd returned error")
+	expected := []string{"<rows>", "  <row>", "    <a>1</a>", "    <b>2</b>", "  </row>", "</rows>", ""}
+	assert.Equal(t, strings.Join(expected, SqlcmdEol), buf.buf.String())
+}
+
 func TestAddErrorStripsMssqlPrefixByDefault(t *testing.T) {
 	out, errOut := new(strings.Builder), new(strings.Builder)
 	vars := InitializeVariables(false)
@@ -202,3 +221,244 @@ func TestAddErrorWithRawErrorsAppliesToAsciiFormatter(t *testing.T) {
 
 	assert.Contains(t, errOut.String(), "mssql: Something failed", "ascii formatter must honor WithRawErrors")
 }
+
+func TestNumberedFileName(t *testing.T) {
+	out, err := os.Create(filepath.Join(t.TempDir(), "results.parquet"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer out.Close()
+	name, err := numberedFileName(out, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(filepath.Dir(out.Name()), "results_3.parquet"), name)
+	_, err = numberedFileName(os.Stdout, 2)
+	assert.Error(t, err, "stdout has no file name to number")
+}
+
+func TestDisplayValueHonorsFormatVariables(t *testing.T) {
+	vars := InitializeVariables(false)
+	f := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore).(*sqlCmdFormatterType)
+	f.BeginBatch("", vars, new(strings.Builder), new(strings.Builder))
+	f.columnDetails = make([]columnDetail, 5)
+	for i, c := range [][]string{{"n", "DECIMAL"}, {"i", "BIGINT"}, {"f", "FLOAT"}, {"d", "DATETIME"}, {"t", "TIME"}} {
+		setColumnInfo(&f.columnDetails[i].col, c[0], c[1])
+	}
+	f.columnDetails[0].precision, f.columnDetails[0].scale = 10, 4
+	f.columnDetails[4].scale = 2
+	d := time.Date(2024, 1, 2, 3, 4, 5, 678000000, time.UTC)
+	tm := time.Date(1, 1, 1, 0, 1, 2, 500000000, time.UTC)
+
+	assert.Equal(t, "NULL", f.displayValue(0, nil))
+	assert.Equal(t, "2024-01-02 03:04:05.678", f.displayValue(3, d), "ODBC format is the default")
+	assert.Equal(t, "1234567.8950", f.displayValue(0, []byte("1234567.8950")), "numbers are printed as returned by default")
+
+	vars.Set(SQLCMDNULLDISPLAY, "(null)")
+	vars.Set(SQLCMDDATETIMEFORMAT, "ISO8601")
+	vars.Set(SQLCMDNUMBERFORMAT, "#,##0.00")
+	assert.Equal(t, "(null)", f.displayValue(0, nil))
+	assert.Equal(t, "2024-01-02T03:04:05.678", f.displayValue(3, d))
+	assert.Equal(t, "1,234,567.90", f.displayValue(0, []byte("1234567.8950")), "halves round away from zero")
+	assert.Equal(t, "-1,000.00", f.displayValue(1, int64(-1000)))
+	assert.Equal(t, "100,000,000,000,000,000,000.00", f.displayValue(2, 1e20))
+
+	vars.Set(SQLCMDDATETIMEFORMAT, "epoch")
+	vars.Set(SQLCMDNUMBERFORMAT, "0")
+	assert.Equal(t, "1704164645.678", f.displayValue(3, d))
+	assert.Equal(t, "62.50", f.displayValue(4, tm), "TIME is the seconds since midnight")
+	assert.Equal(t, "-0.500", epochString(time.Date(1969, 12, 31, 23, 59, 59, 500000000, time.UTC), "DATETIME", 0))
+	assert.Equal(t, "1234568", f.displayValue(0, []byte("1234567.8950")))
+
+	vars.Set(SQLCMDNUMBERFORMAT, "#,##0.00")
+	f.columnDetails[0].displayWidth = 8
+	f.widenFormattedNumbers()
+	assert.Equal(t, int64(11), f.columnDetails[0].displayWidth, "columns make room for separators")
+}
+
+func TestFormatNumber(t *testing.T) {
+	assert.Equal(t, "1,234", formatNumber("1234", "#,##0"))
+	assert.Equal(t, "999", formatNumber("999", "#,##0"))
+	assert.Equal(t, "-123,456.000", formatNumber("-123456", "#,##0.000"))
+	assert.Equal(t, "0.10", formatNumber("0.1", "0.00"))
+	assert.Equal(t, "12.5", formatNumber("12.5", "0.0#"), "unsupported patterns leave the value unchanged")
+	assert.Equal(t, "abc", formatNumber("abc", "0"))
+}
+
+// setColumnLength sets the length the driver reports for a column
+func setColumnLength(c *sql.ColumnType, length int64) {
+	v := reflect.ValueOf(c).Elem()
+	for name, value := range map[string]reflect.Value{"hasLength": reflect.ValueOf(true), "length": reflect.ValueOf(length)} {
+		field := v.FieldByName(name)
+		if field.IsValid() {
+			reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Set(value)
+		}
+	}
+}
+
+func TestDecodeHierarchyID(t *testing.T) {
+	tests := []struct {
+		hex  string
+		path string
+	}{
+		{"", "/"},
+		{"58", "/1/"},
+		{"68", "/2/"},
+		{"5AC0", "/1/1/"},
+		{"62C0", "/1.1/"},
+		{"3F80", "/-1/"},
+		{"E02640", "/100/"},
+	}
+	for _, test := range tests {
+		b, _ := hex.DecodeString(test.hex)
+		path, err := decodeHierarchyID(b)
+		if assert.NoError(t, err, test.hex) {
+			assert.Equal(t, test.path, path, test.hex)
+		}
+	}
+	for _, invalid := range []string{"FF", "60", "E0"} {
+		b, _ := hex.DecodeString(invalid)
+		_, err := decodeHierarchyID(b)
+		assert.ErrorIs(t, err, errInvalidHierarchyID, invalid)
+	}
+}
+
+func TestFormatValueOfNewerTypes(t *testing.T) {
+	vars := InitializeVariables(false)
+	f := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore).(*sqlCmdFormatterType)
+	f.BeginBatch("", vars, new(strings.Builder), new(strings.Builder))
+	f.columnDetails = make([]columnDetail, 2)
+	for i, c := range [][]string{{"v", "SQL_VARIANT"}, {"h", "HIERARCHYID"}} {
+		setColumnInfo(&f.columnDetails[i].col, c[0], c[1])
+	}
+
+	assert.Equal(t, "42", f.displayValue(0, int64(42)))
+	assert.Equal(t, "1", f.displayValue(0, true))
+	assert.Equal(t, "0x31322E3530", f.displayValue(0, []byte("12.50")), "bytes of any base type print as hex")
+	assert.Equal(t, "0x3432", f.displayValue(0, []byte("42")), "binary values that look like numbers aren't printed as numbers")
+	assert.Equal(t, "0x0A0B", f.displayValue(0, []byte{10, 11}))
+	assert.Equal(t, "abc", f.displayValue(0, "abc"))
+	d := time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	assert.Equal(t, "2024-01-02 03:04:05.6", f.displayValue(0, d))
+	d = time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", -8*3600))
+	assert.Equal(t, "2024-01-02 03:04:05 -08:00", f.displayValue(0, d))
+	assert.Equal(t, "NULL", f.displayValue(0, nil))
+
+	assert.Equal(t, "/1/1/", f.displayValue(1, []byte{0x5A, 0xC0}))
+	assert.Equal(t, "0xFF", f.displayValue(1, []byte{0xFF}), "invalid values are printed as hex")
+}
+
+func TestCalcColumnDetailsOfNewerTypes(t *testing.T) {
+	cols := make([]*sql.ColumnType, 2)
+	for i, c := range [][]string{{"variant", "SQL_VARIANT"}, {"node", "HIERARCHYID"}} {
+		cols[i] = new(sql.ColumnType)
+		setColumnInfo(cols[i], c[0], c[1])
+	}
+	setColumnLength(cols[1], 892)
+	details, _ := calcColumnDetails(cols, 0, 256)
+	assert.Equal(t, int64(8000), details[0].displayWidth, "sql_variant keeps the width of ODBC sqlcmd")
+	assert.Equal(t, int64(256), details[1].displayWidth, "hierarchyid paths use the variable width")
+	for _, d := range details {
+		assert.True(t, d.leftJustify, d.col.Name())
+	}
+	details, _ = calcColumnDetails(cols, 0, 0)
+	assert.Equal(t, int64(0), details[1].displayWidth, "a variable width of 0 prints values without padding")
+}
+
+// valuesFormatter records the rows it receives
+type valuesFormatter struct {
+	*sqlCmdFormatterType
+	rows [][]interface{}
+}
+
+func (f *valuesFormatter) AddValues(values []interface{}) {
+	f.rows = append(f.rows, append([]interface{}(nil), values...))
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	var created *valuesFormatter
+	RegisterFormatter("Test-Values", func(vars *Variables) Formatter {
+		created = &valuesFormatter{sqlCmdFormatterType: &sqlCmdFormatterType{vars: vars}}
+		return created
+	})
+	defer func() {
+		formattersMu.Lock()
+		delete(registeredFormatters, "test-values")
+		formattersMu.Unlock()
+	}()
+	vars := InitializeVariables(false)
+	vars.Set(SQLCMDFORMAT, "TEST-values")
+	assert.Equal(t, "test-values", vars.Format())
+	f := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore)
+	assert.Same(t, created, f)
+	assert.Contains(t, RegisteredFormatters(), "test-values")
+	assert.Implements(t, (*RowFormatter)(nil), f)
+
+	assert.Panics(t, func() { RegisterFormatter("test-values", NewSQLCmdJsonFormatter) }, "a name can't be registered twice")
+	assert.Panics(t, func() { RegisterFormatter("JSON", NewSQLCmdJsonFormatter) }, "built-in formatters can't be replaced")
+	assert.Panics(t, func() { RegisterFormatter("other", nil) })
+	vars.Set(SQLCMDFORMAT, "other")
+	assert.Equal(t, "horizontal", vars.Format(), "unknown names select the default format")
+}
+
+func TestBuiltinFormattersAddValues(t *testing.T) {
+	for _, format := range []string{"horizontal", "vert", "ascii", "csv", "markdown", "html", "json", "insert"} {
+		vars := InitializeVariables(false)
+		vars.Set(SQLCMDFORMAT, format)
+		vars.Set(SQLCMDMAXVARTYPEWIDTH, "0")
+		out := new(strings.Builder)
+		f := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore)
+		rf, ok := f.(RowFormatter)
+		require.True(t, ok, "%s formatter implements RowFormatter", format)
+		f.BeginBatch("select", vars, out, out)
+		cols := make([]*sql.ColumnType, 2)
+		for i, c := range [][]string{{"id", "INT"}, {"name", "NVARCHAR"}} {
+			cols[i] = new(sql.ColumnType)
+			setColumnInfo(cols[i], c[0], c[1])
+		}
+		f.BeginResultSet(cols)
+		row := []interface{}{int64(7), "seven"}
+		rf.AddValues(row)
+		row[0], row[1] = int64(8), nil
+		rf.AddValues(row)
+		f.EndResultSet()
+		f.EndBatch()
+		assert.Contains(t, out.String(), "seven", format)
+		assert.Contains(t, out.String(), "8", format)
+	}
+}
+
+// columnsFormatter is a RowFormatter that doesn't read rows itself
+type columnsFormatter struct {
+	Formatter
+	columns []Column
+}
+
+func (f *columnsFormatter) BeginColumns(columns []Column) {
+	f.columns = columns
+}
+
+func (f *columnsFormatter) AddValues(values []interface{}) {}
+
+func TestRowFormatterWithoutAddRow(t *testing.T) {
+	vars := InitializeVariables(false)
+	vars.Set(SQLCMDMAXVARTYPEWIDTH, "20")
+	var f Formatter = &columnsFormatter{}
+	_, ok := f.(RowFormatter)
+	assert.True(t, ok, "AddRow isn't needed to receive rows")
+	_, ok = f.(RowScanner)
+	assert.False(t, ok)
+
+	cols := make([]*sql.ColumnType, 2)
+	for i, c := range [][]string{{"id", "INT"}, {"name", "NVARCHAR"}} {
+		cols[i] = new(sql.ColumnType)
+		setColumnInfo(cols[i], c[0], c[1])
+	}
+	setColumnLength(cols[1], 2147483647)
+	f.(RowFormatter).BeginColumns(newColumns(cols, vars))
+	columns := f.(*columnsFormatter).columns
+	require.Len(t, columns, 2)
+	assert.Equal(t, "name", columns[1].Type().Name())
+	assert.Equal(t, int64(11), columns[0].DisplayWidth())
+	assert.Equal(t, int64(20), columns[1].DisplayWidth(), "widths follow SQLCMDMAXVARTYPEWIDTH")
+	assert.False(t, columns[0].LeftJustify(), "numbers are aligned to the right")
+	assert.True(t, columns[1].LeftJustify())
+}
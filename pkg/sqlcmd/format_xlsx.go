@@ -0,0 +1,357 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"archive/zip"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/microsoft/go-sqlcmd/internal/color"
+)
+
+const (
+	// xlsxMaxColumnWidth caps the width of wide columns such as nvarchar(max)
+	xlsxMaxColumnWidth = 80
+	// xlsxMaxCellLength is the maximum number of characters Excel allows in a cell
+	xlsxMaxCellLength = 32767
+)
+
+// Indexes of the cell formats defined in xlsxStyles
+const (
+	xlsxStyleDefault = iota
+	xlsxStyleHeader
+	xlsxStyleDate
+	xlsxStyleDateTime
+	xlsxStyleTime
+)
+
+const xlsxXmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+const xlsxStyles = xlsxXmlHeader + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="3"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/><numFmt numFmtId="165" formatCode="yyyy-mm-dd hh:mm:ss.000"/><numFmt numFmtId="166" formatCode="hh:mm:ss.000"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="5"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="166" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>` +
+	`</styleSheet>`
+
+// xlsxEpoch is day 0 of the Excel 1900 date system, adjusted for the fictitious leap day in 1900.
+// Dates before that day, which is serial number 60, are one day earlier than the adjusted count.
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxLeapDay is the serial number of 1900-03-01, the first date after the fictitious 1900-02-29
+const xlsxLeapDay = 61
+
+// xlsxFormatter writes each batch as an Excel workbook with one worksheet per result set.
+// The first workbook written to an output goes to the output itself, later ones go to
+// numbered files next to it. Messages are written to the error stream since the output is binary.
+// The console isn't a valid output.
+type xlsxFormatter struct {
+	*sqlCmdFormatterType
+	// output is the file the workbook numbering applies to
+	output    outputFile
+	workbooks int
+	file      *os.File
+	zip       *zip.Writer
+	sheet     io.Writer
+	sheets    int
+	writeErr  error
+}
+
+// NewSQLCmdXlsxFormatter returns a formatter that writes result sets as Excel worksheets
+func NewSQLCmdXlsxFormatter(vars *Variables) Formatter {
+	return &xlsxFormatter{
+		sqlCmdFormatterType: &sqlCmdFormatterType{
+			format:    "xlsx",
+			colorizer: color.New(false),
+			vars:      vars,
+		},
+	}
+}
+
+// BeginBatch sends errors to stderr when they would otherwise be mixed into the binary output
+func (f *xlsxFormatter) BeginBatch(query string, vars *Variables, out io.Writer, err io.Writer) {
+	f.sqlCmdFormatterType.BeginBatch(query, vars, out, err)
+	if err == out {
+		f.err = os.Stderr
+	}
+}
+
+// EndBatch completes the workbook if the batch returned any result sets
+func (f *xlsxFormatter) EndBatch() {
+	if f.zip == nil {
+		return
+	}
+	f.writeWorkbookParts()
+	if err := f.zip.Close(); err != nil && f.writeErr == nil {
+		f.writeErr = err
+	}
+	if f.file != nil {
+		if err := f.file.Close(); err != nil && f.writeErr == nil {
+			f.writeErr = err
+		}
+	}
+	if f.writeErr != nil {
+		f.AddError(f.writeErr)
+	}
+	f.zip, f.file, f.sheet, f.sheets, f.writeErr = nil, nil, nil, 0, nil
+}
+
+// beginWorkbook starts the zip package for the workbook of the current batch
+func (f *xlsxFormatter) beginWorkbook() bool {
+	if err := requireOutputFile(f.out, "xlsx"); err != nil {
+		f.AddError(err)
+		return false
+	}
+	if o := currentOutputFile(f.out); o != f.output {
+		f.output = o
+		f.workbooks = 0
+	}
+	f.workbooks++
+	w := f.out
+	if f.workbooks > 1 {
+		name, err := numberedFileName(f.out, f.workbooks)
+		if err == nil {
+			f.file, err = os.Create(name)
+		}
+		if err != nil {
+			f.AddError(err)
+			return false
+		}
+		w = f.file
+	}
+	f.zip = zip.NewWriter(w)
+	return true
+}
+
+func (f *xlsxFormatter) BeginResultSet(cols []*sql.ColumnType) {
+	f.BeginColumns(newColumns(cols, f.vars))
+}
+
+func (f *xlsxFormatter) BeginColumns(columns []Column) {
+	f.sqlCmdFormatterType.BeginColumns(columns)
+	f.sheet = nil
+	if f.zip == nil && !f.beginWorkbook() {
+		return
+	}
+	f.startSheet()
+}
+
+// startSheet adds a worksheet for the current result set and writes the column widths and header row
+func (f *xlsxFormatter) startSheet() {
+	f.sheets++
+	sheet, err := f.zip.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", f.sheets))
+	if err != nil {
+		f.writeErr = err
+		return
+	}
+	f.sheet = sheet
+	b := new(strings.Builder)
+	b.WriteString(xlsxXmlHeader + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(f.columnDetails) > 0 {
+		b.WriteString("<cols>")
+		for i, c := range f.columnDetails {
+			width := min64(c.displayWidth, xlsxMaxColumnWidth)
+			width = max64(width, int64(stringWidth(c.col.Name())))
+			fmt.Fprintf(b, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, width+2)
+		}
+		b.WriteString("</cols>")
+	}
+	b.WriteString("<sheetData>")
+	if f.vars.RowsBetweenHeaders() > -1 {
+		b.WriteString("<row>")
+		for _, c := range f.columnDetails {
+			writeXlsxString(b, c.col.Name(), xlsxStyleHeader)
+		}
+		b.WriteString("</row>")
+	}
+	f.write(b.String())
+}
+
+func (f *xlsxFormatter) EndResultSet() {
+	if f.sheet != nil {
+		f.write("</sheetData></worksheet>")
+		f.sheet = nil
+	}
+	// The output moves to a new file before the next result set, so the workbook ends here
+	if splitsResultSets(f.out) {
+		f.EndBatch()
+	}
+}
+
+// AddRow writes the current row to the worksheet
+func (f *xlsxFormatter) AddRow(row *sql.Rows) string {
+	return f.addRow(row, f)
+}
+
+// AddValues writes a row to the worksheet
+func (f *xlsxFormatter) AddValues(values []interface{}) {
+	if f.sheet != nil {
+		f.writeRow(values)
+	}
+}
+
+// writeRow writes one worksheet row, keeping numbers and dates as native Excel values
+func (f *xlsxFormatter) writeRow(values []interface{}) {
+	b := new(strings.Builder)
+	b.WriteString("<row>")
+	for i, v := range values {
+		c := &f.columnDetails[i].col
+		switch x := v.(type) {
+		case nil:
+			b.WriteString("<c/>")
+		case bool:
+			if x {
+				b.WriteString(`<c t="b"><v>1</v></c>`)
+			} else {
+				b.WriteString(`<c t="b"><v>0</v></c>`)
+			}
+		case int64:
+			b.WriteString("<c><v>" + strconv.FormatInt(x, 10) + "</v></c>")
+		case float64:
+			b.WriteString("<c><v>" + strconv.FormatFloat(x, 'g', -1, 64) + "</v></c>")
+		case []byte:
+			if isNumericType(c.DatabaseTypeName()) {
+				b.WriteString("<c><v>" + string(x) + "</v></c>")
+			} else {
+				writeXlsxString(b, f.textValue(i, v), xlsxStyleDefault)
+			}
+		case time.Time:
+			writeXlsxTime(b, x, c.DatabaseTypeName(), f.columnDetails[i].scale)
+		default:
+			writeXlsxString(b, f.textValue(i, v), xlsxStyleDefault)
+		}
+	}
+	b.WriteString("</row>")
+	f.write(b.String())
+	f.rowcount++
+}
+
+// textValue returns the text of a value that has no native Excel type
+func (f *xlsxFormatter) textValue(n int, v interface{}) string {
+	s := f.formatValue(n, v)
+	if _, file := v.(blobFile); !file && isNeedingHexPrefix(&f.columnDetails[n].col) {
+		s = "0x" + s
+	}
+	return s
+}
+
+// AddMessage writes the message to the error stream since it can't be part of a workbook
+func (f *xlsxFormatter) AddMessage(msg string) {
+	f.mustWriteMessage(msg)
+}
+
+// write adds s to the current worksheet, remembering the first error to report at the end of the batch
+func (f *xlsxFormatter) write(s string) {
+	if f.writeErr != nil || f.sheet == nil {
+		return
+	}
+	_, f.writeErr = io.WriteString(f.sheet, s)
+}
+
+// writeWorkbookParts writes the package parts that list the worksheets
+func (f *xlsxFormatter) writeWorkbookParts() {
+	contentTypes := new(strings.Builder)
+	contentTypes.WriteString(xlsxXmlHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook := new(strings.Builder)
+	workbook.WriteString(xlsxXmlHeader + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels := new(strings.Builder)
+	rels.WriteString(xlsxXmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= f.sheets; i++ {
+		fmt.Fprintf(contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+		fmt.Fprintf(workbook, `<sheet name="ResultSet%d" sheetId="%d" r:id="rId%d"/>`, i, i, i)
+		fmt.Fprintf(rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	contentTypes.WriteString("</Types>")
+	workbook.WriteString("</sheets></workbook>")
+	fmt.Fprintf(rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`, f.sheets+1)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypes.String()},
+		{"_rels/.rels", xlsxXmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", rels.String()},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		if f.writeErr != nil {
+			return
+		}
+		var w io.Writer
+		if w, f.writeErr = f.zip.Create(part.name); f.writeErr == nil {
+			_, f.writeErr = io.WriteString(w, part.content)
+		}
+	}
+}
+
+// writeXlsxTime writes t as an Excel date serial number. Datetimeoffset values are written
+// as text since Excel dates have no time zone, as are dates Excel can't represent.
+func writeXlsxTime(b *strings.Builder, t time.Time, typeName string, scale int) {
+	style := xlsxStyleDateTime
+	switch typeName {
+	case "DATE":
+		style = xlsxStyleDate
+	case "TIME":
+		style = xlsxStyleTime
+	}
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	if typeName == "TIME" {
+		wall = time.Date(xlsxEpoch.Year(), xlsxEpoch.Month(), xlsxEpoch.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	} else if typeName == "DATETIMEOFFSET" || t.Year() < 1900 {
+		writeXlsxString(b, t.Format(isoDateTimeFormatString(typeName, scale)), xlsxStyleDefault)
+		return
+	}
+	// time.Duration can't span the whole date range so count seconds instead
+	seconds := float64(wall.Unix()-xlsxEpoch.Unix()) + float64(wall.Nanosecond())/float64(time.Second)
+	days := seconds / (24 * 60 * 60)
+	if typeName != "TIME" && days < xlsxLeapDay {
+		days--
+	}
+	fmt.Fprintf(b, `<c s="%d"><v>%s</v></c>`, style, strconv.FormatFloat(days, 'f', -1, 64))
+}
+
+// writeXlsxString writes s as an inline string cell. Characters XML can't represent are dropped.
+func writeXlsxString(b *strings.Builder, s string, style int) {
+	if style == xlsxStyleDefault {
+		b.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+	} else {
+		fmt.Fprintf(b, `<c t="inlineStr" s="%d"><is><t xml:space="preserve">`, style)
+	}
+	n := 0
+	for _, r := range s {
+		if n == xlsxMaxCellLength {
+			break
+		}
+		n++
+		switch {
+		case r == '<':
+			b.WriteString("&lt;")
+		case r == '>':
+			b.WriteString("&gt;")
+		case r == '&':
+			b.WriteString("&amp;")
+		case r < 0x20 && r != '\t' && r != '\n' && r != '\r', r == 0xFFFE, r == 0xFFFF, r == utf8.RuneError:
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteString("</t></is></c>")
+}
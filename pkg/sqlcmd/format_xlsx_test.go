@@ -0,0 +1,138 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type xlsxTestSheet struct {
+	Cols []struct {
+		Width string `xml:"width,attr"`
+	} `xml:"cols>col"`
+	Rows []struct {
+		Cells []struct {
+			Type   string `xml:"t,attr"`
+			Style  string `xml:"s,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readXlsxPart(t *testing.T, name string, part string) string {
+	t.Helper()
+	r, err := zip.OpenReader(name)
+	require.NoError(t, err, "the workbook must be a valid zip package")
+	defer r.Close()
+	f, err := r.Open(part)
+	require.NoError(t, err, part)
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestXlsxFormatterWorkbook(t *testing.T) {
+	vars := InitializeVariables(false)
+	vars.Set(SQLCMDFORMAT, "xlsx")
+	f := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore).(*xlsxFormatter)
+	name := filepath.Join(t.TempDir(), "report.xlsx")
+	out, err := os.Create(name)
+	require.NoError(t, err)
+	defer out.Close()
+
+	for batch := 0; batch < 2; batch++ {
+		f.BeginBatch("", vars, out, out)
+		require.True(t, f.beginWorkbook())
+		f.columnDetails = make([]columnDetail, 6)
+		for i, c := range [][]string{{"id", "INT"}, {"name", "NVARCHAR"}, {"price", "DECIMAL"}, {"created", "DATETIME2"}, {"born", "DATE"}, {"data", "VARBINARY"}} {
+			setColumnInfo(&f.columnDetails[i].col, c[0], c[1])
+			f.columnDetails[i].displayWidth = 10
+		}
+		f.columnDetails[1].displayWidth = defaultMaxDisplayWidth
+		f.startSheet()
+		f.writeRow([]interface{}{int64(1), "<one> & \x01", []byte("12.50"), time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC), time.Date(1900, 3, 1, 0, 0, 0, 0, time.UTC), []byte{0xca, 0xfe}})
+		f.writeRow([]interface{}{nil, nil, nil, nil, nil, nil})
+		f.EndResultSet()
+		f.startSheet()
+		f.EndResultSet()
+		f.EndBatch()
+	}
+	require.NoError(t, out.Close())
+
+	workbook := readXlsxPart(t, name, "xl/workbook.xml")
+	assert.Contains(t, workbook, `<sheet name="ResultSet1" sheetId="1" r:id="rId1"/><sheet name="ResultSet2" sheetId="2" r:id="rId2"/>`)
+	assert.Contains(t, readXlsxPart(t, name, "[Content_Types].xml"), `/xl/worksheets/sheet2.xml`)
+	assert.Contains(t, readXlsxPart(t, name, "xl/_rels/workbook.xml.rels"), `Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles"`)
+
+	var sheet xlsxTestSheet
+	require.NoError(t, xml.Unmarshal([]byte(readXlsxPart(t, name, "xl/worksheets/sheet1.xml")), &sheet))
+	require.Len(t, sheet.Cols, 6)
+	assert.Equal(t, "12", sheet.Cols[0].Width)
+	assert.Equal(t, "82", sheet.Cols[1].Width, "wide columns are capped")
+	require.Len(t, sheet.Rows, 3)
+	header := sheet.Rows[0].Cells
+	assert.Equal(t, "inlineStr", header[0].Type)
+	assert.Equal(t, "1", header[0].Style, "headers use the bold style")
+	assert.Equal(t, "id", header[0].Inline)
+	cells := sheet.Rows[1].Cells
+	assert.Equal(t, "", cells[0].Type, "integers are numbers")
+	assert.Equal(t, "1", cells[0].Value)
+	assert.Equal(t, "<one> & ", cells[1].Inline, "text is escaped and invalid XML characters are dropped")
+	assert.Equal(t, "12.50", cells[2].Value)
+	assert.Equal(t, "45293.5", cells[3].Value, "datetimes are date serial numbers")
+	assert.Equal(t, "3", cells[3].Style)
+	assert.Equal(t, "61", cells[4].Value)
+	assert.Equal(t, "2", cells[4].Style)
+	assert.Equal(t, "0xCAFE", cells[5].Inline)
+	assert.Len(t, sheet.Rows[2].Cells, 6, "NULL values keep their cell position")
+
+	second := filepath.Join(filepath.Dir(name), "report_2.xlsx")
+	assert.Contains(t, readXlsxPart(t, second, "xl/workbook.xml"), "ResultSet2", "the second batch is written to a numbered workbook")
+}
+
+func TestXlsxFormatterRequiresOutputFile(t *testing.T) {
+	vars := InitializeVariables(false)
+	f := NewSQLCmdXlsxFormatter(vars).(*xlsxFormatter)
+	errOut := new(strings.Builder)
+	f.BeginBatch("", vars, os.Stdout, errOut)
+	f.BeginResultSet(nil)
+	assert.Nil(t, f.zip, "no workbook is written to the console")
+	f.AddMessage("(1 row affected)")
+	f.EndResultSet()
+	f.EndBatch()
+	assert.Equal(t, "Results were not written. The xlsx format requires an output file name. Use :OUT or -o to set one"+SqlcmdEol+"(1 row affected)"+SqlcmdEol, errOut.String())
+}
+
+func TestWriteXlsxTime(t *testing.T) {
+	b := new(strings.Builder)
+	writeXlsxTime(b, time.Date(1, 1, 1, 18, 0, 0, 0, time.UTC), "TIME", 0)
+	assert.Equal(t, `<c s="4"><v>0.75</v></c>`, b.String())
+	b.Reset()
+	writeXlsxTime(b, time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), "DATE", 0)
+	assert.Equal(t, `<c s="2"><v>1</v></c>`, b.String(), "1900-01-01 is day 1")
+	b.Reset()
+	writeXlsxTime(b, time.Date(1900, 2, 28, 12, 0, 0, 0, time.UTC), "DATETIME", 3)
+	assert.Equal(t, `<c s="3"><v>59.5</v></c>`, b.String(), "dates before the fictitious 1900-02-29")
+	b.Reset()
+	writeXlsxTime(b, time.Date(1900, 3, 1, 0, 0, 0, 0, time.UTC), "DATE", 0)
+	assert.Equal(t, `<c s="2"><v>61</v></c>`, b.String(), "dates after the fictitious 1900-02-29")
+	b.Reset()
+	writeXlsxTime(b, time.Date(1753, 1, 1, 0, 0, 0, 0, time.UTC), "DATETIME", 3)
+	assert.Equal(t, `<c t="inlineStr"><is><t xml:space="preserve">1753-01-01T00:00:00.000</t></is></c>`, b.String(), "dates before 1900 are text")
+	b.Reset()
+	writeXlsxTime(b, time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600)), "DATETIMEOFFSET", 0)
+	assert.Equal(t, `<c t="inlineStr"><is><t xml:space="preserve">2024-01-02T03:04:05+01:00</t></is></c>`, b.String())
+}
@@ -0,0 +1,129 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var errInvalidHierarchyID = errors.New("invalid hierarchyid data")
+
+// hierarchyIDPattern describes the bits of one hierarchyid label in a value range. The pattern
+// starts with the prefix that selects the range, followed by the value bits (x) interleaved with
+// fixed bits, and ends with the terminator (T). The terminator is 1 for the last label of a level
+// and 0 when another label follows, as in /1.2/.
+type hierarchyIDPattern struct {
+	min     int64
+	pattern string
+}
+
+var hierarchyIDPatterns = []hierarchyIDPattern{
+	{0, "01xxT"},
+	{4, "100xxT"},
+	{8, "101xxxT"},
+	{16, "110xx0x1xxxT"},
+	{80, "1110xxx0xxx0x1xxxT"},
+	{1104, "11110xxxxx0xxx0x1xxxT"},
+	{5200, "111110xxxxxxxxxxxxxxxxxxx0xxxxxx0xxx0x1xxxT"},
+	{4294972496, "111111xxxxxxxxxxxxxx0xxxxxxxxxxxxxxxxxxxxx0xxxxxx0xxx0x1xxxT"},
+	{-8, "00111xxxT"},
+	{-72, "0010xx0x1xxxT"},
+	{-4168, "000111xxxxx0xxx0x1xxxT"},
+	{-4294971464, "000110xxxxxxxxxxxxxxxxxxx0xxxxxx0xxx0x1xxxT"},
+	{-281479271682120, "000101xxxxxxxxxxxxxx0xxxxxxxxxxxxxxxxxxxxx0xxxxxx0xxx0x1xxxT"},
+}
+
+// bitReader reads a byte slice one bit at a time, most significant bit first
+type bitReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *bitReader) bit() (byte, bool) {
+	if r.pos >= len(r.b)*8 {
+		return 0, false
+	}
+	bit := (r.b[r.pos/8] >> (7 - r.pos%8)) & 1
+	r.pos++
+	return bit, true
+}
+
+// peek returns true if the next bits match the prefix
+func (r *bitReader) peek(prefix string) bool {
+	for i := 0; i < len(prefix); i++ {
+		p := r.pos + i
+		if p >= len(r.b)*8 || (r.b[p/8]>>(7-p%8))&1 != prefix[i]-'0' {
+			return false
+		}
+	}
+	return true
+}
+
+// atEnd returns true if only the zero padding of the last byte is left
+func (r *bitReader) atEnd() bool {
+	for p := r.pos; p < len(r.b)*8; p++ {
+		if (r.b[p/8]>>(7-p%8))&1 != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeHierarchyID converts the OrdPath encoding of a hierarchyid value to its path form, such as /1/2.1/
+func decodeHierarchyID(b []byte) (string, error) {
+	r := &bitReader{b: b}
+	w := new(strings.Builder)
+	w.WriteString("/")
+	for !r.atEnd() {
+		value, last, err := r.label()
+		if err != nil {
+			return "", err
+		}
+		// labels followed by another label of the same level are stored incremented by one
+		if !last {
+			value--
+		}
+		w.WriteString(strconv.FormatInt(value, 10))
+		if last {
+			w.WriteString("/")
+		} else {
+			w.WriteString(".")
+		}
+	}
+	if s := w.String(); s != "/" && !strings.HasSuffix(s, "/") {
+		return "", errInvalidHierarchyID
+	}
+	return w.String(), nil
+}
+
+// label reads the next label and returns its value and whether it ends the level
+func (r *bitReader) label() (int64, bool, error) {
+	for _, p := range hierarchyIDPatterns {
+		prefix := p.pattern[:strings.IndexByte(p.pattern, 'x')]
+		if !r.peek(prefix) {
+			continue
+		}
+		r.pos += len(prefix)
+		var value int64
+		for _, c := range []byte(p.pattern[len(prefix):]) {
+			bit, ok := r.bit()
+			if !ok {
+				return 0, false, errInvalidHierarchyID
+			}
+			switch c {
+			case 'x':
+				value = value<<1 | int64(bit)
+			case 'T':
+				return p.min + value, bit == 1, nil
+			default:
+				if bit != c-'0' {
+					return 0, false, errInvalidHierarchyID
+				}
+			}
+		}
+	}
+	return 0, false, errInvalidHierarchyID
+}
@@ -0,0 +1,249 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/microsoft/go-sqlcmd/internal/localizer"
+	"golang.org/x/term"
+)
+
+// builtinPager is the SQLCMDPAGER value that selects the pager built into sqlcmd
+const builtinPager = "builtin"
+
+// pagerScrollColumns is the number of columns the built-in pager scrolls horizontally per key press
+const pagerScrollColumns = 8
+
+// pagerBuffer collects the output of a batch so it can be shown in a pager.
+// It records which lines hold column headings so the built-in pager can keep them on screen.
+type pagerBuffer struct {
+	bytes.Buffer
+	// headers are the [first, end) line ranges of column headings
+	headers [][2]int
+}
+
+func (p *pagerBuffer) lineCount() int {
+	return bytes.Count(p.Bytes(), []byte("\n"))
+}
+
+// beginHeader marks the next line written as the first line of column headings
+func (p *pagerBuffer) beginHeader() {
+	n := p.lineCount()
+	p.headers = append(p.headers, [2]int{n, n})
+}
+
+// endHeader marks the end of the column headings started by beginHeader
+func (p *pagerBuffer) endHeader() {
+	p.headers[len(p.headers)-1][1] = p.lineCount()
+}
+
+// pager displays buffered output one screen at a time, scrolling vertically and horizontally
+type pager struct {
+	lines   []string
+	headers [][2]int
+	top     int
+	left    int
+	width   int
+	height  int
+}
+
+func newPager(b *pagerBuffer, width int, height int) *pager {
+	text := strings.TrimSuffix(strings.ReplaceAll(b.String(), "\r\n", "\n"), "\n")
+	return &pager{
+		lines:   strings.Split(text, "\n"),
+		headers: b.headers,
+		width:   width,
+		height:  height,
+	}
+}
+
+// fits returns true when the whole output can be shown without scrolling
+func (p *pager) fits() bool {
+	if len(p.lines) >= p.height {
+		return false
+	}
+	for _, l := range p.lines {
+		if stringWidth(l) > p.width {
+			return false
+		}
+	}
+	return true
+}
+
+// rows is the number of screen lines available to the output. The last line shows the status.
+func (p *pager) rows() int {
+	return max(p.height-1, 1)
+}
+
+// frozenHeader returns the column headings of the rows starting at line top when they have scrolled off the screen
+func (p *pager) frozenHeader(top int) []string {
+	for i := len(p.headers) - 1; i >= 0; i-- {
+		h := p.headers[i]
+		if h[0] < top {
+			if h[1] > top || h[1]-h[0] >= p.rows() {
+				return nil
+			}
+			return p.lines[h[0]:h[1]]
+		}
+	}
+	return nil
+}
+
+// bodyRows is the number of output lines shown below the frozen headings
+func (p *pager) bodyRows(top int) int {
+	return max(p.rows()-len(p.frozenHeader(top)), 1)
+}
+
+// maxTop is the first line shown when the end of the output is on screen
+func (p *pager) maxTop() int {
+	top := max(len(p.lines)-p.rows(), 0)
+	for top > 0 && top+p.bodyRows(top) < len(p.lines) {
+		top++
+	}
+	return top
+}
+
+func (p *pager) maxLeft() int {
+	w := 0
+	for _, l := range p.lines {
+		w = max(w, stringWidth(l))
+	}
+	return max(w-p.width, 0)
+}
+
+// view returns the screen lines for the current position
+func (p *pager) view() []string {
+	screen := make([]string, 0, p.height)
+	for _, l := range p.frozenHeader(p.top) {
+		screen = append(screen, p.cut(l))
+	}
+	end := min(p.top+p.bodyRows(p.top), len(p.lines))
+	for _, l := range p.lines[p.top:end] {
+		screen = append(screen, p.cut(l))
+	}
+	for len(screen) < p.rows() {
+		screen = append(screen, "~")
+	}
+	status := localizer.Sprintf("Lines %d-%d of %d, column %d. Use the arrow keys to scroll, q to quit.", p.top+1, end, len(p.lines), p.left+1)
+	return append(screen, p.cut(status))
+}
+
+// cut returns the part of the line that is visible at the current horizontal position
+func (p *pager) cut(line string) string {
+	line = skipWidth(line, p.left)
+	if stringWidth(line) > p.width {
+		return truncateWidth(line, p.width)
+	}
+	return line
+}
+
+// handleKey moves the view for a key press. It returns false when the pager should close.
+func (p *pager) handleKey(key string) bool {
+	switch key {
+	case "q", "Q", "\x1b", "\x03":
+		return false
+	case "j", "\r", "\n", "\x1b[B", "\x1bOB":
+		p.top++
+	case "k", "\x1b[A", "\x1bOA":
+		p.top--
+	case "l", "\x1b[C", "\x1bOC":
+		p.left += pagerScrollColumns
+	case "h", "\x1b[D", "\x1bOD":
+		p.left -= pagerScrollColumns
+	case " ", "f", "\x1b[6~":
+		p.top += p.bodyRows(p.top)
+	case "b", "\x1b[5~":
+		p.top -= p.bodyRows(p.top)
+	case "g", "\x1b[H", "\x1b[1~":
+		p.top, p.left = 0, 0
+	case "G", "\x1b[F", "\x1b[4~":
+		p.top = p.maxTop()
+	}
+	p.clamp()
+	return true
+}
+
+// clamp keeps the view within the output
+func (p *pager) clamp() {
+	p.top = min(max(p.top, 0), p.maxTop())
+	p.left = min(max(p.left, 0), p.maxLeft())
+}
+
+// run shows the output on an alternate screen until the user quits.
+// in must be a terminal in raw mode. size returns the current terminal width and height.
+func (p *pager) run(in io.Reader, out io.Writer, size func() (int, int)) error {
+	_, _ = io.WriteString(out, "\x1b[?1049h\x1b[?25l")
+	defer func() { _, _ = io.WriteString(out, "\x1b[?25h\x1b[?1049l") }()
+	key := make([]byte, 16)
+	for {
+		if w, h := size(); w > 0 && h > 0 {
+			p.width, p.height = w, h
+			p.clamp()
+		}
+		if _, err := io.WriteString(out, "\x1b[H\x1b[2J"+strings.Join(p.view(), "\x1b[K\r\n")); err != nil {
+			return err
+		}
+		n, err := in.Read(key)
+		if err != nil {
+			return err
+		}
+		if !p.handleKey(string(key[:n])) {
+			return nil
+		}
+	}
+}
+
+// pagerOutput returns a buffer for the output of a batch when the results should be shown in a pager.
+// Paging applies to interactive sessions that write to a terminal.
+func (s *Sqlcmd) pagerOutput() *pagerBuffer {
+	if s.lineIo == nil || s.pagerCommand() == "" || consoleFile(s.GetOutput()) != os.Stdout || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil
+	}
+	return new(pagerBuffer)
+}
+
+// pagerCommand returns the pager selected by SQLCMDPAGER. An external pager runs like :!!, so the
+// built-in pager replaces it when system commands are disabled.
+func (s *Sqlcmd) pagerCommand() string {
+	command := s.vars.Pager()
+	if command != "" && command != builtinPager && s.Cmd.sysCommandsDisabled() {
+		return builtinPager
+	}
+	return command
+}
+
+// showPage displays the output of a batch in the pager. Output that fits on the screen is written as-is.
+func (s *Sqlcmd) showPage(b *pagerBuffer) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	p := newPager(b, width, height)
+	if err != nil || p.fits() {
+		_, _ = os.Stdout.Write(b.Bytes())
+		return
+	}
+	if command := s.pagerCommand(); command != builtinPager {
+		cmd := sysCommand(command)
+		cmd.Stdin = bytes.NewReader(b.Bytes())
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if cmd.Run() != nil {
+			_, _ = os.Stdout.Write(b.Bytes())
+		}
+		return
+	}
+	stdin := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		_, _ = os.Stdout.Write(b.Bytes())
+		return
+	}
+	defer func() { _ = term.Restore(stdin, state) }()
+	_ = p.run(os.Stdin, os.Stdout, func() (int, int) {
+		w, h, _ := term.GetSize(int(os.Stdout.Fd()))
+		return w, h
+	})
+}
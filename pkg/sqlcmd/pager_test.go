@@ -0,0 +1,112 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pagerTestBuffer(t *testing.T, rows int) *pagerBuffer {
+	t.Helper()
+	vars := InitializeVariables(false)
+	b := new(pagerBuffer)
+	f := NewSQLCmdDefaultFormatter(vars, true, ControlIgnore).(*sqlCmdFormatterType)
+	f.BeginBatch("", vars, b, b)
+	f.AddMessage("Changed database context to 'master'.")
+	f.columnDetails = make([]columnDetail, 2)
+	setColumnInfo(&f.columnDetails[0].col, "id", "INT")
+	setColumnInfo(&f.columnDetails[1].col, "name", "NVARCHAR")
+	f.columnDetails[0].displayWidth, f.columnDetails[1].displayWidth = 2, 4
+	f.printColumnHeadings()
+	for i := 1; i <= rows; i++ {
+		f.mustWriteOut(fmt.Sprintf("%d row%d%s", i, i, SqlcmdEol), 0)
+	}
+	f.EndBatch()
+	return b
+}
+
+func TestPagerBufferMarksHeadings(t *testing.T) {
+	b := pagerTestBuffer(t, 3)
+	require.Len(t, b.headers, 1)
+	assert.Equal(t, [2]int{1, 3}, b.headers[0], "the column names and separator follow the message line")
+	p := newPager(b, 80, 24)
+	assert.Equal(t, []string{"id name", "-- ----"}, p.lines[1:3])
+	assert.True(t, p.fits())
+	p.width = 5
+	assert.False(t, p.fits(), "wide lines need horizontal scrolling")
+}
+
+func TestPagerKeepsHeadingsOnScreen(t *testing.T) {
+	p := newPager(pagerTestBuffer(t, 20), 10, 6)
+	assert.False(t, p.fits())
+	view := p.view()
+	require.Len(t, view, 6)
+	assert.Equal(t, []string{"Changed da", "id name", "-- ----", "1 row1", "2 row2"}, view[:5])
+	assert.True(t, strings.HasPrefix(view[5], "Lines 1-5"), view[5])
+
+	assert.True(t, p.handleKey(" "))
+	view = p.view()
+	assert.Equal(t, []string{"id name", "-- ----", "3 row3", "4 row4", "5 row5"}, view[:5], "the headings stay on screen")
+
+	p.handleKey("G")
+	view = p.view()
+	assert.Equal(t, "20 row20", view[4], "the last page ends with the last line")
+	p.handleKey("j")
+	assert.Equal(t, view, p.view(), "scrolling stops at the end")
+
+	p.handleKey("g")
+	p.handleKey("\x1b[C")
+	assert.Equal(t, 8, p.left)
+	for i := 0; i < 4; i++ {
+		p.handleKey("\x1b[C")
+	}
+	assert.Equal(t, 27, p.left, "horizontal scrolling stops at the widest line")
+	assert.Equal(t, " 'master'.", p.view()[0])
+	p.handleKey("\x1b[D")
+	assert.Equal(t, 19, p.left)
+	assert.False(t, p.handleKey("q"))
+}
+
+func TestPagerRun(t *testing.T) {
+	p := newPager(pagerTestBuffer(t, 20), 10, 6)
+	out := new(strings.Builder)
+	err := p.run(iotest.OneByteReader(strings.NewReader("jq")), out, func() (int, int) { return 12, 6 })
+	assert.NoError(t, err)
+	assert.Equal(t, 1, p.top)
+	assert.Equal(t, 12, p.width, "the view follows the terminal size")
+	assert.True(t, strings.HasPrefix(out.String(), "\x1b[?1049h"), "the pager uses the alternate screen")
+	assert.True(t, strings.HasSuffix(out.String(), "\x1b[?1049l"), "the pager restores the screen")
+}
+
+func TestPagerVariable(t *testing.T) {
+	vars := InitializeVariables(false)
+	assert.Equal(t, "", vars.Pager(), "paging is off by default")
+	vars.Set(SQLCMDPAGER, "BuiltIn")
+	assert.Equal(t, builtinPager, vars.Pager())
+	vars.Set(SQLCMDPAGER, "less -S")
+	assert.Equal(t, "less -S", vars.Pager())
+	t.Setenv("PAGER", "more")
+	vars.Set(SQLCMDPAGER, "on")
+	assert.Equal(t, "more", vars.Pager())
+	t.Setenv("PAGER", "")
+	assert.Equal(t, builtinPager, vars.Pager())
+}
+
+func TestPagerCommandWithDisabledSysCommands(t *testing.T) {
+	vars := InitializeVariables(false)
+	s := New(nil, "", vars)
+	vars.Set(SQLCMDPAGER, "less -S")
+	assert.Equal(t, "less -S", s.pagerCommand())
+	// -X disables the system commands
+	s.Cmd.DisableSysCommands(false)
+	assert.Equal(t, builtinPager, s.pagerCommand(), "an external pager can't run when system commands are disabled")
+	vars.Set(SQLCMDPAGER, "off")
+	assert.Equal(t, "", s.pagerCommand(), "paging stays off")
+}
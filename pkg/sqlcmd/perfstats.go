@@ -0,0 +1,69 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/microsoft/go-sqlcmd/internal/localizer"
+)
+
+// PerfStatsFormat selects the client performance statistics printed after each batch
+type PerfStatsFormat int
+
+const (
+	// PerfStatsNone prints no statistics
+	PerfStatsNone PerfStatsFormat = iota
+	// PerfStatsText prints the statistics in the layout of ODBC sqlcmd -p
+	PerfStatsText
+	// PerfStatsColon prints the statistics as colon-separated values, like ODBC sqlcmd -p1
+	PerfStatsColon
+)
+
+// perfStats returns the statistics of a batch that ran count times in elapsed time with the requested packet size.
+// The clock time is in milliseconds and each execution of the batch counts as one transaction.
+func perfStats(format PerfStatsFormat, packetSize int, count int, elapsed time.Duration) string {
+	total := elapsed.Milliseconds()
+	avg := float64(elapsed.Microseconds()) / 1000 / float64(count)
+	perSecond := 0.0
+	if elapsed > 0 {
+		perSecond = float64(count) / elapsed.Seconds()
+	}
+	if format == PerfStatsColon {
+		return fmt.Sprintf("%d:%d:%d:%.2f:%.2f", packetSize, count, total, avg, perSecond) + SqlcmdEol
+	}
+	// the numbers are formatted without separators so scripts can parse them.
+	// The driver doesn't report the packet size the server agreed to, so the requested size is printed.
+	return localizer.Sprintf("Requested network packet size (bytes): %s", strconv.Itoa(packetSize)) + SqlcmdEol +
+		localizer.Sprintf("%s xact[s]:", strconv.Itoa(count)) + SqlcmdEol +
+		localizer.Sprintf("Clock Time (ms.): total %s  avg %s (%s xacts per sec.)", fmt.Sprintf("%9d", total), fmt.Sprintf("%6.2f", avg), fmt.Sprintf("%.2f", perSecond)) + SqlcmdEol
+}
+
+// statisticsMessages are the numbers of the informational messages sent by SET STATISTICS IO and
+// SET STATISTICS TIME, which :PERFTRACE redirects away from the output
+var statisticsMessages = []int32{
+	3612, // SQL Server Execution Times
+	3613, // SQL Server parse and compile time
+	3615, // Table 'name'. Scan count
+}
+
+// isStatisticsMessage returns true if msg is a SET STATISTICS message from the server
+func isStatisticsMessage(msg fmt.Stringer) bool {
+	if e, ok := msg.(mssql.Error); ok {
+		return slices.Contains(statisticsMessages, e.Number)
+	}
+	return false
+}
+
+// printPerfStats writes the statistics of a batch to the performance trace when PerfStats is set
+func (s *Sqlcmd) printPerfStats(count int, elapsed time.Duration) {
+	if s.PerfStats == PerfStatsNone {
+		return
+	}
+	_, _ = s.GetPerfTrace().Write([]byte(perfStats(s.PerfStats, s.vars.PacketSize(), count, elapsed)))
+}
@@ -0,0 +1,110 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPerfStats(t *testing.T) {
+	expected := []string{
+		"Requested network packet size (bytes): 4096",
+		"4 xact[s]:",
+		"Clock Time (ms.): total        10  avg   2.50 (400.00 xacts per sec.)",
+		"",
+	}
+	assert.Equal(t, strings.Join(expected, SqlcmdEol), perfStats(PerfStatsText, 4096, 4, 10*time.Millisecond))
+	assert.Equal(t, "8192:1:1500:1500.00:0.67"+SqlcmdEol, perfStats(PerfStatsColon, 8192, 1, 1500*time.Millisecond))
+	assert.Equal(t, "4096:1:0:0.00:0.00"+SqlcmdEol, perfStats(PerfStatsColon, 4096, 1, 0), "batches that take no measurable time")
+}
+
+func TestPrintPerfStats(t *testing.T) {
+	vars := InitializeVariables(false)
+	s := New(nil, "", vars)
+	out := &memoryBuffer{buf: new(bytes.Buffer)}
+	s.SetOutput(out)
+	defer s.SetOutput(nil)
+	s.printPerfStats(1, time.Millisecond)
+	assert.Empty(t, out.buf.String(), "no statistics by default")
+	s.PerfStats = PerfStatsColon
+	vars.Set(SQLCMDPACKETSIZE, "512")
+	s.printPerfStats(2, 4*time.Millisecond)
+	assert.Equal(t, "512:2:4:2.00:500.00"+SqlcmdEol, out.buf.String())
+}
+
+func TestPerfTraceCommand(t *testing.T) {
+	vars := InitializeVariables(false)
+	s := New(nil, "", vars)
+	out := &memoryBuffer{buf: new(bytes.Buffer)}
+	s.SetOutput(out)
+	defer s.SetOutput(nil)
+	defer s.SetPerfTrace(nil)
+	s.PerfStats = PerfStatsColon
+	vars.Set(SQLCMDPACKETSIZE, "512")
+
+	err := perfTraceCommand(s, []string{""}, 1)
+	assert.EqualError(t, err, InvalidCommandError("PERFTRACE", 1).Error(), ":PERFTRACE without a file name")
+
+	fileName := filepath.Join(t.TempDir(), "perf.log")
+	err = perfTraceCommand(s, []string{fileName}, 1)
+	require.NoError(t, err, ":PERFTRACE file")
+	s.printPerfStats(2, 4*time.Millisecond)
+	s.SetPerfTrace(nil)
+	assert.Empty(t, out.buf.String(), "statistics aren't written to the output")
+	text, err := os.ReadFile(fileName)
+	if assert.NoError(t, err, "ReadFile") {
+		assert.Equal(t, "512:2:4:2.00:500.00"+SqlcmdEol, string(text), "statistics in the trace file")
+	}
+
+	vars.Set("myvar", "stderr")
+	err = perfTraceCommand(s, []string{"$(myvar)"}, 1)
+	assert.NoError(t, err, ":PERFTRACE with a variable")
+	assert.Equal(t, os.Stderr, s.perf, "trace set to stderr using a variable")
+}
+
+func TestPerfTraceReceivesStatisticsMessages(t *testing.T) {
+	s, buf := setupSqlCmdWithMemoryOutput(t)
+	defer buf.Close()
+	defer s.SetPerfTrace(nil)
+	fileName := filepath.Join(t.TempDir(), "perf.log")
+	err := perfTraceCommand(s, []string{fileName}, 1)
+	require.NoError(t, err, ":PERFTRACE file")
+	err = runSqlCmd(t, s, []string{"SET STATISTICS IO ON", "SELECT COUNT(*) FROM sys.objects", "SET STATISTICS IO OFF", "GO"})
+	assert.NoError(t, err, "runSqlCmd")
+	s.SetPerfTrace(nil)
+	assert.NotContains(t, buf.buf.String(), "Scan count", "statistics in the output")
+	text, err := os.ReadFile(fileName)
+	if assert.NoError(t, err, "ReadFile") {
+		assert.Contains(t, string(text), "Scan count", "statistics in the trace file")
+	}
+}
+
+func TestGoRepetitionFailureWritesStatistics(t *testing.T) {
+	s, buf := setupSqlCmdWithMemoryOutput(t)
+	defer buf.Close()
+	defer s.SetPerfTrace(nil)
+	trace := &memoryBuffer{buf: new(bytes.Buffer)}
+	s.SetPerfTrace(trace)
+	s.PerfStats = PerfStatsColon
+	s.Connect.ExitOnError = true
+	err := runSqlCmd(t, s, []string{"RAISERROR(N'failed', 16, 1)", "GO 3"})
+	assert.NoError(t, err, "runSqlCmd")
+	assert.True(t, strings.HasPrefix(trace.buf.String(), "4096:1:"), "statistics of the execution that failed: %s", trace.buf.String())
+}
+
+func TestIsStatisticsMessage(t *testing.T) {
+	assert.True(t, isStatisticsMessage(mssql.Error{Number: 3615, Message: "Table 'sysobjects'. Scan count 1"}), "STATISTICS IO")
+	assert.True(t, isStatisticsMessage(mssql.Error{Number: 3613, Message: "SQL Server parse and compile time:"}), "STATISTICS TIME")
+	assert.False(t, isStatisticsMessage(mssql.Error{Number: 5701, Message: "Changed database context to 'master'."}), "other messages")
+	assert.False(t, isStatisticsMessage(textMessage("(1 row affected)")), "row counts")
+}
@@ -0,0 +1,450 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Serialization property flags of the SQL Server CLR spatial format
+const (
+	spatialHasZ                = 0x01
+	spatialHasM                = 0x02
+	spatialIsSinglePoint       = 0x08
+	spatialIsSingleLineSegment = 0x10
+)
+
+// OpenGIS shape types
+const (
+	shapePoint              = 1
+	shapeLineString         = 2
+	shapePolygon            = 3
+	shapeMultiPoint         = 4
+	shapeMultiLineString    = 5
+	shapeMultiPolygon       = 6
+	shapeGeometryCollection = 7
+	shapeCircularString     = 8
+	shapeCompoundCurve      = 9
+	shapeCurvePolygon       = 10
+	shapeFullGlobe          = 11
+)
+
+// Figure attributes of version 2 of the format
+const (
+	figureLine      = 1
+	figureArc       = 2
+	figureComposite = 3
+)
+
+// Segment types of compound curves
+const (
+	segmentLine      = 0
+	segmentArc       = 1
+	segmentFirstLine = 2
+	segmentFirstArc  = 3
+)
+
+var errInvalidSpatialData = errors.New("invalid spatial data")
+
+var shapeNames = map[byte]string{
+	shapePoint:              "POINT",
+	shapeLineString:         "LINESTRING",
+	shapePolygon:            "POLYGON",
+	shapeMultiPoint:         "MULTIPOINT",
+	shapeMultiLineString:    "MULTILINESTRING",
+	shapeMultiPolygon:       "MULTIPOLYGON",
+	shapeGeometryCollection: "GEOMETRYCOLLECTION",
+	shapeCircularString:     "CIRCULARSTRING",
+	shapeCompoundCurve:      "COMPOUNDCURVE",
+	shapeCurvePolygon:       "CURVEPOLYGON",
+	shapeFullGlobe:          "FULLGLOBE",
+}
+
+type spatialFigure struct {
+	attribute  byte
+	pointStart int
+}
+
+type spatialShape struct {
+	parent      int
+	figureStart int
+	kind        byte
+}
+
+// spatialValue is a decoded geometry or geography value
+type spatialValue struct {
+	srid     int32
+	x, y     []float64
+	z, m     []float64
+	figures  []spatialFigure
+	shapes   []spatialShape
+	segments []byte
+	// segment is the next segment of a compound curve to write
+	segment int
+}
+
+// decodeSpatial converts the SQL Server serialization of a geometry or geography value to
+// Well-Known Text and returns it with the SRID of the value. Geography points are stored as
+// latitude and longitude, which WKT writes in longitude, latitude order.
+func decodeSpatial(b []byte, geography bool) (string, int32, error) {
+	v, err := parseSpatial(b, geography)
+	if err != nil {
+		return "", 0, err
+	}
+	w := new(strings.Builder)
+	if err = v.writeShape(w, 0, true); err != nil {
+		return "", 0, err
+	}
+	return w.String(), v.srid, nil
+}
+
+// spatialReader reads the little-endian values of the serialization format
+type spatialReader struct {
+	b   []byte
+	err bool
+}
+
+func (r *spatialReader) next(n int) []byte {
+	if r.err || len(r.b) < n {
+		r.err = true
+		return make([]byte, n)
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *spatialReader) byte() byte {
+	return r.next(1)[0]
+}
+
+func (r *spatialReader) int32() int32 {
+	return int32(binary.LittleEndian.Uint32(r.next(4)))
+}
+
+func (r *spatialReader) count() int {
+	n := r.int32()
+	if n < 0 || int(n) > len(r.b) {
+		r.err = true
+		return 0
+	}
+	return int(n)
+}
+
+func (r *spatialReader) float64() float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(r.next(8)))
+}
+
+func parseSpatial(b []byte, geography bool) (*spatialValue, error) {
+	r := &spatialReader{b: b}
+	v := &spatialValue{srid: r.int32()}
+	version := r.byte()
+	flags := r.byte()
+	if r.err || version < 1 || version > 2 {
+		return nil, errInvalidSpatialData
+	}
+	points := 0
+	switch {
+	case flags&spatialIsSinglePoint != 0:
+		points = 1
+	case flags&spatialIsSingleLineSegment != 0:
+		points = 2
+	default:
+		points = r.count()
+	}
+	v.x, v.y = make([]float64, points), make([]float64, points)
+	for i := 0; i < points; i++ {
+		v.x[i], v.y[i] = r.float64(), r.float64()
+		if geography {
+			v.x[i], v.y[i] = v.y[i], v.x[i]
+		}
+	}
+	if flags&spatialHasZ != 0 {
+		v.z = make([]float64, points)
+		for i := range v.z {
+			v.z[i] = r.float64()
+		}
+	}
+	if flags&spatialHasM != 0 {
+		v.m = make([]float64, points)
+		for i := range v.m {
+			v.m[i] = r.float64()
+		}
+	}
+	switch {
+	case flags&spatialIsSinglePoint != 0:
+		v.figures = []spatialFigure{{attribute: figureLine}}
+		v.shapes = []spatialShape{{parent: -1, kind: shapePoint}}
+	case flags&spatialIsSingleLineSegment != 0:
+		v.figures = []spatialFigure{{attribute: figureLine}}
+		v.shapes = []spatialShape{{parent: -1, kind: shapeLineString}}
+	default:
+		v.figures = make([]spatialFigure, r.count())
+		for i := range v.figures {
+			v.figures[i].attribute = r.byte()
+			v.figures[i].pointStart = int(r.int32())
+		}
+		v.shapes = make([]spatialShape, r.count())
+		for i := range v.shapes {
+			v.shapes[i].parent = int(r.int32())
+			v.shapes[i].figureStart = int(r.int32())
+			v.shapes[i].kind = r.byte()
+		}
+		if version == 2 && len(r.b) > 0 {
+			v.segments = r.next(r.count())
+		}
+	}
+	if r.err {
+		return nil, errInvalidSpatialData
+	}
+	if !v.valid() {
+		return nil, errInvalidSpatialData
+	}
+	return v, nil
+}
+
+// valid checks that the figures and shapes refer to points and figures of the value in
+// increasing order, so the ranges of each figure and shape are within bounds
+func (v *spatialValue) valid() bool {
+	if len(v.shapes) == 0 || v.shapes[0].parent != -1 {
+		return false
+	}
+	start := 0
+	for _, f := range v.figures {
+		if f.pointStart < start || f.pointStart > len(v.x) {
+			return false
+		}
+		start = f.pointStart
+	}
+	start = 0
+	for i, s := range v.shapes {
+		if i > 0 && (s.parent < 0 || s.parent >= i) {
+			return false
+		}
+		if s.figureStart < 0 {
+			if s.figureStart != -1 {
+				return false
+			}
+			continue
+		}
+		if s.figureStart < start || s.figureStart > len(v.figures) {
+			return false
+		}
+		start = s.figureStart
+	}
+	return true
+}
+
+// figureRange returns the figures of shape i
+func (v *spatialValue) figureRange(i int) (int, int) {
+	start := v.shapes[i].figureStart
+	if start < 0 {
+		return 0, 0
+	}
+	for j := i + 1; j < len(v.shapes); j++ {
+		if v.shapes[j].figureStart >= 0 {
+			return start, v.shapes[j].figureStart
+		}
+	}
+	return start, len(v.figures)
+}
+
+// pointRange returns the points of figure i
+func (v *spatialValue) pointRange(i int) (int, int) {
+	end := len(v.x)
+	if i+1 < len(v.figures) {
+		end = v.figures[i+1].pointStart
+	}
+	return v.figures[i].pointStart, end
+}
+
+// children returns the shapes whose parent is shape i
+func (v *spatialValue) children(i int) []int {
+	var c []int
+	for j := i + 1; j < len(v.shapes); j++ {
+		if v.shapes[j].parent == i {
+			c = append(c, j)
+		}
+	}
+	return c
+}
+
+// writeShape writes shape i. The name of the shape type is omitted for
+// the members of multi-geometries other than collections.
+func (v *spatialValue) writeShape(w *strings.Builder, i int, named bool) error {
+	s := v.shapes[i]
+	name, ok := shapeNames[s.kind]
+	if !ok {
+		return errInvalidSpatialData
+	}
+	if named {
+		w.WriteString(name)
+		if s.kind == shapeFullGlobe {
+			return nil
+		}
+		w.WriteString(" ")
+	}
+	first, last := v.figureRange(i)
+	if first > last || last > len(v.figures) {
+		return errInvalidSpatialData
+	}
+	switch s.kind {
+	case shapePoint, shapeLineString, shapeCircularString:
+		if first == last {
+			w.WriteString("EMPTY")
+			return nil
+		}
+		return v.writePoints(w, first)
+	case shapePolygon:
+		if first == last {
+			w.WriteString("EMPTY")
+			return nil
+		}
+		w.WriteString("(")
+		for f := first; f < last; f++ {
+			if f > first {
+				w.WriteString(", ")
+			}
+			if err := v.writePoints(w, f); err != nil {
+				return err
+			}
+		}
+		w.WriteString(")")
+	case shapeCompoundCurve:
+		if first == last {
+			w.WriteString("EMPTY")
+			return nil
+		}
+		return v.writeCompoundCurve(w, first)
+	case shapeCurvePolygon:
+		if first == last {
+			w.WriteString("EMPTY")
+			return nil
+		}
+		w.WriteString("(")
+		for f := first; f < last; f++ {
+			if f > first {
+				w.WriteString(", ")
+			}
+			var err error
+			switch v.figures[f].attribute {
+			case figureArc:
+				w.WriteString("CIRCULARSTRING ")
+				err = v.writePoints(w, f)
+			case figureComposite:
+				w.WriteString("COMPOUNDCURVE ")
+				err = v.writeCompoundCurve(w, f)
+			default:
+				err = v.writePoints(w, f)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		w.WriteString(")")
+	default:
+		children := v.children(i)
+		if len(children) == 0 {
+			w.WriteString("EMPTY")
+			return nil
+		}
+		w.WriteString("(")
+		for n, c := range children {
+			if n > 0 {
+				w.WriteString(", ")
+			}
+			if err := v.writeShape(w, c, s.kind == shapeGeometryCollection); err != nil {
+				return err
+			}
+		}
+		w.WriteString(")")
+	}
+	return nil
+}
+
+// writePoints writes the points of figure f as a parenthesized list
+func (v *spatialValue) writePoints(w *strings.Builder, f int) error {
+	first, last := v.pointRange(f)
+	if first > last || last > len(v.x) {
+		return errInvalidSpatialData
+	}
+	w.WriteString("(")
+	for p := first; p < last; p++ {
+		if p > first {
+			w.WriteString(", ")
+		}
+		v.writePoint(w, p)
+	}
+	w.WriteString(")")
+	return nil
+}
+
+// writeCompoundCurve writes the line and arc parts of figure f. Each segment adds
+// one point to a line or two points to an arc and the first segment of a part
+// starts at the last point of the previous part.
+func (v *spatialValue) writeCompoundCurve(w *strings.Builder, f int) error {
+	first, last := v.pointRange(f)
+	if first > last || last > len(v.x) {
+		return errInvalidSpatialData
+	}
+	w.WriteString("(")
+	p := first
+	parts := 0
+	for v.segment < len(v.segments) && p < last-1 {
+		s := v.segments[v.segment]
+		arc := s == segmentArc || s == segmentFirstArc
+		if parts == 0 || s == segmentFirstLine || s == segmentFirstArc {
+			if parts > 0 {
+				w.WriteString("), ")
+			}
+			if arc {
+				w.WriteString("CIRCULARSTRING ")
+			}
+			w.WriteString("(")
+			v.writePoint(w, p)
+			parts++
+		}
+		n := 1
+		if arc {
+			n = 2
+		}
+		for i := 0; i < n && p < last-1; i++ {
+			p++
+			w.WriteString(", ")
+			v.writePoint(w, p)
+		}
+		v.segment++
+	}
+	if parts > 0 {
+		w.WriteString(")")
+	}
+	w.WriteString(")")
+	return nil
+}
+
+func (v *spatialValue) writePoint(w *strings.Builder, p int) {
+	w.WriteString(formatCoordinate(v.x[p]) + " " + formatCoordinate(v.y[p]))
+	if v.z != nil || v.m != nil {
+		z := math.NaN()
+		if v.z != nil {
+			z = v.z[p]
+		}
+		w.WriteString(" " + formatCoordinate(z))
+	}
+	if v.m != nil {
+		w.WriteString(" " + formatCoordinate(v.m[p]))
+	}
+}
+
+// formatCoordinate writes the shortest representation of a coordinate. Missing Z and M values are NULL.
+func formatCoordinate(f float64) string {
+	if math.IsNaN(f) {
+		return "NULL"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
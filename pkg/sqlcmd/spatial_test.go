@@ -0,0 +1,206 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type spatialFigureData struct {
+	attribute byte
+	point     int32
+}
+
+type spatialShapeData struct {
+	parent int32
+	figure int32
+	kind   byte
+}
+
+// spatialBytes builds the serialization of a spatial value with explicit figures and shapes
+func spatialBytes(srid int32, version byte, flags byte, points []float64, zm []float64, figures []spatialFigureData, shapes []spatialShapeData, segments []byte) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(srid))
+	b = append(b, version, flags)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(points)/2))
+	for _, p := range append(points, zm...) {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(p))
+	}
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(figures)))
+	for _, f := range figures {
+		b = append(b, f.attribute)
+		b = binary.LittleEndian.AppendUint32(b, uint32(f.point))
+	}
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(shapes)))
+	for _, s := range shapes {
+		b = binary.LittleEndian.AppendUint32(b, uint32(s.parent))
+		b = binary.LittleEndian.AppendUint32(b, uint32(s.figure))
+		b = append(b, s.kind)
+	}
+	if segments != nil {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(segments)))
+		b = append(b, segments...)
+	}
+	return b
+}
+
+func TestDecodeSpatialSinglePoint(t *testing.T) {
+	b, _ := hex.DecodeString("00000000010C000000000000F03F0000000000000040")
+	wkt, _, err := decodeSpatial(b, false)
+	require.NoError(t, err)
+	assert.Equal(t, "POINT (1 2)", wkt)
+
+	// geography::Point(47.65, -122.34, 4326)
+	b, _ = hex.DecodeString("E6100000010C3333333333D34740F6285C8FC2955EC0")
+	wkt, srid, err := decodeSpatial(b, true)
+	require.NoError(t, err)
+	assert.Equal(t, "POINT (-122.34 47.65)", wkt, "geography is written in longitude, latitude order")
+	assert.Equal(t, int32(4326), srid, "SRID")
+
+	b = binary.LittleEndian.AppendUint32(nil, 0)
+	b = append(b, 1, 0x0F)
+	for _, f := range []float64{1, 2, 3, 4} {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+	}
+	wkt, _, err = decodeSpatial(b, false)
+	require.NoError(t, err)
+	assert.Equal(t, "POINT (1 2 3 4)", wkt, "Z and M values")
+
+	b = binary.LittleEndian.AppendUint32(nil, 0)
+	b = append(b, 1, 0x15)
+	for _, f := range []float64{0, 0, 1, 1, math.NaN(), 5} {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+	}
+	wkt, _, err = decodeSpatial(b, false)
+	require.NoError(t, err)
+	assert.Equal(t, "LINESTRING (0 0 NULL, 1 1 5)", wkt, "single line segment with a missing Z value")
+}
+
+func TestDecodeSpatialShapes(t *testing.T) {
+	tests := []struct {
+		name     string
+		b        []byte
+		expected string
+	}{
+		{
+			"empty point",
+			spatialBytes(0, 1, 0x04, nil, nil, nil, []spatialShapeData{{-1, -1, shapePoint}}, nil),
+			"POINT EMPTY",
+		},
+		{
+			"polygon with a hole",
+			spatialBytes(0, 1, 0x04,
+				[]float64{0, 0, 10, 0, 10, 10, 0, 0, 2, 2, 3, 2, 3, 3, 2, 2}, nil,
+				[]spatialFigureData{{2, 0}, {0, 4}},
+				[]spatialShapeData{{-1, 0, shapePolygon}}, nil),
+			"POLYGON ((0 0, 10 0, 10 10, 0 0), (2 2, 3 2, 3 3, 2 2))",
+		},
+		{
+			"multipoint",
+			spatialBytes(0, 1, 0x04,
+				[]float64{1, 2, 3, 4}, nil,
+				[]spatialFigureData{{1, 0}, {1, 1}},
+				[]spatialShapeData{{-1, 0, shapeMultiPoint}, {0, 0, shapePoint}, {0, 1, shapePoint}}, nil),
+			"MULTIPOINT ((1 2), (3 4))",
+		},
+		{
+			"multilinestring with Z",
+			spatialBytes(0, 1, 0x05,
+				[]float64{0, 0, 1, 1, 2, 2, 3, 3}, []float64{1, 2, 3, 4},
+				[]spatialFigureData{{1, 0}, {1, 2}},
+				[]spatialShapeData{{-1, 0, shapeMultiLineString}, {0, 0, shapeLineString}, {0, 1, shapeLineString}}, nil),
+			"MULTILINESTRING ((0 0 1, 1 1 2), (2 2 3, 3 3 4))",
+		},
+		{
+			"collection",
+			spatialBytes(4326, 1, 0x04,
+				[]float64{1, 2, 0, 0, 5, 5}, nil,
+				[]spatialFigureData{{1, 0}, {1, 1}},
+				[]spatialShapeData{{-1, 0, shapeGeometryCollection}, {0, 0, shapePoint}, {0, 1, shapeLineString}, {0, -1, shapePolygon}}, nil),
+			"GEOMETRYCOLLECTION (POINT (1 2), LINESTRING (0 0, 5 5), POLYGON EMPTY)",
+		},
+		{
+			"empty collection",
+			spatialBytes(0, 1, 0x04, nil, nil, nil, []spatialShapeData{{-1, -1, shapeGeometryCollection}}, nil),
+			"GEOMETRYCOLLECTION EMPTY",
+		},
+		{
+			"compound curve",
+			spatialBytes(0, 2, 0x04,
+				[]float64{0, 0, 1, 1, 2, 0, 3, 0}, nil,
+				[]spatialFigureData{{figureComposite, 0}},
+				[]spatialShapeData{{-1, 0, shapeCompoundCurve}}, []byte{segmentFirstArc, segmentFirstLine}),
+			"COMPOUNDCURVE (CIRCULARSTRING (0 0, 1 1, 2 0), (2 0, 3 0))",
+		},
+		{
+			"full globe",
+			spatialBytes(4326, 2, 0x04, nil, nil, nil, []spatialShapeData{{-1, -1, shapeFullGlobe}}, nil),
+			"FULLGLOBE",
+		},
+	}
+	for _, test := range tests {
+		wkt, _, err := decodeSpatial(test.b, false)
+		if assert.NoError(t, err, test.name) {
+			assert.Equal(t, test.expected, wkt, test.name)
+		}
+	}
+}
+
+func TestDecodeSpatialInvalidData(t *testing.T) {
+	valid, _ := hex.DecodeString("00000000010C000000000000F03F0000000000000040")
+	for _, b := range [][]byte{
+		nil,
+		valid[:len(valid)-1],
+		append([]byte{0, 0, 0, 0, 3}, valid[5:]...),
+		spatialBytes(0, 1, 0x04, []float64{1, 2}, nil, []spatialFigureData{{1, 5}}, []spatialShapeData{{-1, 0, shapePoint}}, nil),
+		spatialBytes(0, 1, 0x04, []float64{1, 2}, nil, []spatialFigureData{{1, 0}}, []spatialShapeData{{-1, 0, 42}}, nil),
+		// the figures of a polygon that start at decreasing points
+		spatialBytes(0, 1, 0x04, []float64{0, 0, 1, 1, 2, 2}, nil, []spatialFigureData{{2, 2}, {0, 0}}, []spatialShapeData{{-1, 0, shapePolygon}}, nil),
+		// shapes that start at decreasing figures
+		spatialBytes(0, 1, 0x04, []float64{1, 2, 3, 4}, nil, []spatialFigureData{{1, 0}, {1, 1}},
+			[]spatialShapeData{{-1, 0, shapeMultiPoint}, {0, 1, shapePoint}, {0, 0, shapePoint}}, nil),
+		// a shape that starts past the last figure
+		spatialBytes(0, 1, 0x04, []float64{1, 2}, nil, []spatialFigureData{{1, 0}}, []spatialShapeData{{-1, 2, shapePoint}}, nil),
+		// a shape whose parent isn't before it
+		spatialBytes(0, 1, 0x04, []float64{1, 2}, nil, []spatialFigureData{{1, 0}},
+			[]spatialShapeData{{-1, 0, shapeGeometryCollection}, {3, 0, shapePoint}}, nil),
+		// a root shape with a parent
+		spatialBytes(0, 1, 0x04, []float64{1, 2}, nil, []spatialFigureData{{1, 0}}, []spatialShapeData{{0, 0, shapePoint}}, nil),
+		// no shapes
+		spatialBytes(0, 1, 0x04, []float64{1, 2}, nil, []spatialFigureData{{1, 0}}, nil, nil),
+	} {
+		_, _, err := decodeSpatial(b, false)
+		assert.ErrorIs(t, err, errInvalidSpatialData, "%X", b)
+	}
+}
+
+func TestSpatialFormatVariable(t *testing.T) {
+	vars := InitializeVariables(false)
+	f := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore).(*sqlCmdFormatterType)
+	f.BeginBatch("", vars, new(strings.Builder), new(strings.Builder))
+	f.columnDetails = make([]columnDetail, 2)
+	setColumnInfo(&f.columnDetails[0].col, "g", "GEOMETRY")
+	setColumnInfo(&f.columnDetails[1].col, "g", "GEOGRAPHY")
+	b, _ := hex.DecodeString("00000000010C000000000000F03F0000000000000040")
+	assert.Equal(t, "wkt", vars.SpatialFormat())
+	assert.Equal(t, "POINT (1 2)", f.formatValue(0, b))
+	assert.Equal(t, "POINT (2 1)", f.formatValue(1, b))
+	assert.Equal(t, "0x0102", f.formatValue(0, []byte{1, 2}), "values that can't be decoded are printed as hex")
+	invalid := spatialBytes(0, 1, 0x04, []float64{0, 0, 1, 1}, nil, []spatialFigureData{{2, 1}, {0, 0}}, []spatialShapeData{{-1, 0, shapePolygon}}, nil)
+	assert.Equal(t, "0x"+decodeBinary(invalid), f.formatValue(0, invalid), "values with figures out of order are printed as hex")
+	// geography::Point(47.65, -122.34, 4326)
+	g, _ := hex.DecodeString("E6100000010C3333333333D34740F6285C8FC2955EC0")
+	assert.Equal(t, "POINT (-122.34 47.65)", f.formatValue(1, g), "the SRID isn't printed by default")
+	vars.Set(SQLCMDSPATIALFORMAT, "EWKT")
+	assert.Equal(t, "SRID=4326;POINT (-122.34 47.65)", f.formatValue(1, g))
+	assert.Equal(t, "POINT (1 2)", f.formatValue(0, b), "no prefix for SRID 0")
+	vars.Set(SQLCMDSPATIALFORMAT, "HEX")
+	assert.Equal(t, "0x00000000010C000000000000F03F0000000000000040", f.formatValue(0, b))
+}
@@ -0,0 +1,145 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding"
+)
+
+// Placeholders that can be used in :OUT file names to split the output into multiple files
+const (
+	batchPlaceholder     = "{batch}"
+	resultSetPlaceholder = "{resultset}"
+	timestampPlaceholder = "{timestamp}"
+)
+
+// splitOutput is an output writer that switches to a new file when the file name template
+// expands to a new name. Sqlcmd advances the batch and result set numbers as queries run.
+// Files are created when the first output for their name is written. A name that expands
+// the same as an earlier one, such as {resultset} without {batch}, appends to the earlier file.
+type splitOutput struct {
+	template  string
+	encoding  encoding.Encoding
+	batch     int
+	resultSet int
+	started   bool
+	name      string
+	file      *os.File
+	w         io.WriteCloser
+	// created holds the names of the files written so far
+	created map[string]bool
+	// failed is true when the file of the current name couldn't be opened
+	failed bool
+}
+
+// hasOutputPlaceholder returns true if the file name contains a placeholder that splits the output
+func hasOutputPlaceholder(name string) bool {
+	return strings.Contains(name, batchPlaceholder) || strings.Contains(name, resultSetPlaceholder) || strings.Contains(name, timestampPlaceholder)
+}
+
+func newSplitOutput(template string, enc encoding.Encoding) *splitOutput {
+	o := &splitOutput{template: template, encoding: enc, batch: 1, resultSet: 1, created: map[string]bool{}}
+	o.name = o.expand()
+	return o
+}
+
+// expand returns the file name for the current batch, result set, and time
+func (o *splitOutput) expand() string {
+	return strings.NewReplacer(
+		batchPlaceholder, strconv.Itoa(o.batch),
+		resultSetPlaceholder, strconv.Itoa(o.resultSet),
+		timestampPlaceholder, time.Now().Format("20060102T150405"),
+	).Replace(o.template)
+}
+
+// nextBatch moves to the first result set of the next batch
+func (o *splitOutput) nextBatch() {
+	if o.started {
+		o.batch++
+	}
+	o.started = true
+	o.resultSet = 1
+	o.update()
+}
+
+// nextResultSet moves to the next result set of the current batch
+func (o *splitOutput) nextResultSet() {
+	o.resultSet++
+	o.update()
+}
+
+// splitsResultSets returns true if every result set is written to its own file
+func (o *splitOutput) splitsResultSets() bool {
+	return strings.Contains(o.template, resultSetPlaceholder)
+}
+
+// update closes the current file when the expanded name has changed
+func (o *splitOutput) update() {
+	name := o.expand()
+	if name != o.name {
+		_ = o.Close()
+		o.name = name
+		o.failed = false
+	}
+}
+
+// check returns an error if the file of the current name can't be created, without leaving a new file behind
+func (o *splitOutput) check() error {
+	_, statErr := os.Stat(o.name)
+	f, err := os.OpenFile(o.name, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_ = f.Close()
+	if os.IsNotExist(statErr) {
+		_ = os.Remove(o.name)
+	}
+	return nil
+}
+
+// Name returns the name of the file that receives the output
+func (o *splitOutput) Name() string {
+	return o.name
+}
+
+// Write writes to the file of the current name. When the file can't be opened the error is
+// reported on stderr and the output for that name is discarded, so the queries keep running.
+func (o *splitOutput) Write(p []byte) (int, error) {
+	if o.file == nil {
+		if o.failed {
+			return len(p), nil
+		}
+		flags := os.O_TRUNC | os.O_CREATE | os.O_WRONLY
+		if o.created[o.name] {
+			flags = os.O_APPEND | os.O_CREATE | os.O_WRONLY
+		}
+		f, err := os.OpenFile(o.name, flags, 0o644)
+		if err != nil {
+			o.failed = true
+			_, _ = os.Stderr.WriteString(InvalidFileError(err, o.name).Error() + SqlcmdEol)
+			return len(p), nil
+		}
+		o.created[o.name] = true
+		o.file = f
+		o.w = encodeFile(f, o.encoding)
+	}
+	return o.w.Write(p)
+}
+
+// Close closes the current file. A later write opens the file again.
+func (o *splitOutput) Close() error {
+	if o.file == nil {
+		return nil
+	}
+	err := o.w.Close()
+	o.file = nil
+	o.w = nil
+	return err
+}
@@ -0,0 +1,122 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitOutputSwitchesFiles(t *testing.T) {
+	dir := t.TempDir()
+	o := newSplitOutput(filepath.Join(dir, "out_{batch}_{resultset}.txt"), nil)
+	assert.True(t, o.splitsResultSets())
+	for batch := 0; batch < 2; batch++ {
+		o.nextBatch()
+		fmt.Fprintf(o, "batch %d first", batch+1)
+		o.nextResultSet()
+		fmt.Fprintf(o, "batch %d second", batch+1)
+	}
+	o.nextBatch()
+	require.NoError(t, o.Close())
+
+	for _, expected := range []struct{ name, content string }{
+		{"out_1_1.txt", "batch 1 first"},
+		{"out_1_2.txt", "batch 1 second"},
+		{"out_2_1.txt", "batch 2 first"},
+		{"out_2_2.txt", "batch 2 second"},
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, expected.name))
+		if assert.NoError(t, err, expected.name) {
+			assert.Equal(t, expected.content, string(b), expected.name)
+		}
+	}
+	_, err := os.Stat(filepath.Join(dir, "out_3_1.txt"))
+	assert.True(t, os.IsNotExist(err), "files are only created when output is written")
+
+	o = newSplitOutput(filepath.Join(dir, "batch_{batch}.txt"), nil)
+	assert.False(t, o.splitsResultSets())
+	o.nextBatch()
+	fmt.Fprint(o, "first")
+	o.nextResultSet()
+	fmt.Fprint(o, " second")
+	require.NoError(t, o.Close())
+	b, err := os.ReadFile(filepath.Join(dir, "batch_1.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "first second", string(b), "the file is kept while the expanded name is unchanged")
+}
+
+func TestSplitOutputAppendsToRepeatedNames(t *testing.T) {
+	dir := t.TempDir()
+	o := newSplitOutput(filepath.Join(dir, "out_{resultset}.txt"), nil)
+	for batch := 0; batch < 2; batch++ {
+		o.nextBatch()
+		fmt.Fprintf(o, "batch %d first;", batch+1)
+		o.nextResultSet()
+		fmt.Fprintf(o, "batch %d second;", batch+1)
+	}
+	require.NoError(t, o.Close())
+	b, err := os.ReadFile(filepath.Join(dir, "out_1.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "batch 1 first;batch 2 first;", string(b), "later batches don't overwrite the files of earlier batches")
+	b, err = os.ReadFile(filepath.Join(dir, "out_2.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "batch 1 second;batch 2 second;", string(b))
+}
+
+func TestSplitOutputOpenErrors(t *testing.T) {
+	dir := t.TempDir()
+	// a folder in place of the file of the second batch makes it fail to open
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "out_2.txt"), 0o755))
+	o := newSplitOutput(filepath.Join(dir, "out_{batch}.txt"), nil)
+	assert.NoError(t, o.check(), "the first file can be created")
+	_, err := os.Stat(filepath.Join(dir, "out_1.txt"))
+	assert.True(t, os.IsNotExist(err), "check doesn't leave a file behind")
+	o.nextBatch()
+	fmt.Fprint(o, "first")
+	o.nextBatch()
+	n, err := fmt.Fprint(o, "second")
+	assert.NoError(t, err, "open errors don't fail the write")
+	assert.Equal(t, len("second"), n)
+	o.nextBatch()
+	fmt.Fprint(o, "third")
+	require.NoError(t, o.Close())
+	b, err := os.ReadFile(filepath.Join(dir, "out_3.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "third", string(b), "the output continues with the next file")
+
+	o = newSplitOutput(filepath.Join(dir, "out_2.txt", "{batch}", "x.txt"), nil)
+	assert.Error(t, o.check(), "check reports files that can't be created")
+}
+
+func TestOutCommandWithPlaceholders(t *testing.T) {
+	vars := InitializeVariables(false)
+	s := New(nil, "", vars)
+	text := NewSQLCmdDefaultFormatter(vars, false, ControlIgnore)
+	s.Format = text
+	defer s.SetOutput(nil)
+	dir := t.TempDir()
+
+	err := outCommand(s, []string{filepath.Join(dir, "results_{batch}.txt")}, 1)
+	assert.NoError(t, err, "outCommand")
+	assert.IsType(t, &splitOutput{}, s.GetOutput())
+	assert.Same(t, text, s.Format)
+
+	err = outCommand(s, []string{filepath.Join(dir, "results_{resultset}.xlsx")}, 1)
+	assert.NoError(t, err, "outCommand")
+	assert.IsType(t, &xlsxFormatter{}, s.Format, "binary formats are selected by the extension of the template")
+	assert.True(t, splitsResultSets(s.GetOutput()))
+
+	err = outCommand(s, []string{filepath.Join(dir, "missing", "results_{batch}.txt")}, 1)
+	assert.Error(t, err, "the folder of the files must exist")
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "results_1.txt"), 0o755))
+	err = outCommand(s, []string{filepath.Join(dir, "results_{batch}.txt")}, 1)
+	assert.IsType(t, &FileError{}, err, "files that can't be created are reported by :OUT")
+}
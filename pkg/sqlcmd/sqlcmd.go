@@ -27,7 +27,7 @@ import (
 	"github.com/microsoft/go-mssqldb/msdsn"
 	"github.com/microsoft/go-sqlcmd/internal/color"
 	"github.com/microsoft/go-sqlcmd/internal/localizer"
-	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding"
 	"golang.org/x/text/transform"
 )
 
@@ -67,6 +67,7 @@ type Sqlcmd struct {
 	db               *sql.Conn
 	out              io.WriteCloser
 	err              io.WriteCloser
+	perf             io.WriteCloser
 	batch            *Batch
 	echoFileLines    bool
 	// Exitcode is returned to the operating system when the process exits
@@ -76,6 +77,8 @@ type Sqlcmd struct {
 	vars    *Variables
 	// Format renders the query output
 	Format Formatter
+	// textFormat is the formatter to restore when :OUT switches away from a file with a binary format
+	textFormat Formatter
 	// Query is the TSQL query to run
 	Query string
 	// Cmd provides the implementation of commands like :list and GO
@@ -84,8 +87,26 @@ type Sqlcmd struct {
 	PrintError func(msg string, severity uint8) bool
 	// UnicodeOutputFile is true when UTF16 file output is needed
 	UnicodeOutputFile bool
+	// InputEncoding is the code page of input files set by -f. UTF-8 is used when it's nil.
+	InputEncoding encoding.Encoding
+	// OutputEncoding is the code page of output and error files set by -f. It takes precedence over UnicodeOutputFile.
+	OutputEncoding encoding.Encoding
 	// EchoInput tells the GO command to print the batch text before running the query
 	EchoInput bool
+	// PerfStats selects the performance statistics the GO command prints after running a batch
+	PerfStats PerfStatsFormat
+	// ErrorsFormat selects how errors and messages are reported
+	ErrorsFormat ErrorsFormat
+	// eventOutput receives the events of ErrorsFormatJSON instead of stderr
+	eventOutput io.Writer
+	// scriptFile is the input file being run by IncludeFile
+	scriptFile string
+	// lineOffset is the number of lines read before the current input file
+	lineOffset uint
+	// blobOut writes large values to files while :BLOBOUT is on
+	blobOut *blobOutput
+	// tee receives a copy of the output while :TEE is on
+	tee       *teeFile
 	colorizer color.Colorizer
 	termchan  chan os.Signal
 }
@@ -206,10 +227,11 @@ func (s *Sqlcmd) RunCommand(cmd *Command, args []string) error {
 
 // GetOutput returns the io.Writer to use for non-error output
 func (s *Sqlcmd) GetOutput() io.Writer {
-	if s.out == nil {
-		return os.Stdout
+	var out io.Writer = os.Stdout
+	if s.out != nil {
+		out = s.out
 	}
-	return s.out
+	return s.console(out)
 }
 
 // SetOutput sets the io.WriteCloser to use for non-error output
@@ -220,12 +242,34 @@ func (s *Sqlcmd) SetOutput(o io.WriteCloser) {
 	s.out = o
 }
 
+// setFileFormatter replaces the formatter with one required by the output file type
+func (s *Sqlcmd) setFileFormatter(f Formatter) {
+	if s.textFormat == nil {
+		s.textFormat = s.Format
+	}
+	if s.Format != nil {
+		f.XmlMode(s.Format.IsXmlMode())
+		setXmlIndent(f, isXmlIndent(s.Format))
+	}
+	s.Format = f
+}
+
+// restoreFormatter reverts a formatter set by setFileFormatter
+func (s *Sqlcmd) restoreFormatter() {
+	if s.textFormat != nil {
+		s.textFormat.XmlMode(s.Format.IsXmlMode())
+		setXmlIndent(s.textFormat, isXmlIndent(s.Format))
+		s.Format = s.textFormat
+		s.textFormat = nil
+	}
+}
+
 // GetError returns the io.Writer to use for errors
 func (s *Sqlcmd) GetError() io.Writer {
 	if s.err == nil {
 		return s.GetOutput()
 	}
-	return s.err
+	return s.console(s.err)
 }
 
 // SetError sets the io.WriteCloser to use for errors
@@ -236,16 +280,38 @@ func (s *Sqlcmd) SetError(e io.WriteCloser) {
 	s.err = e
 }
 
-// WriteError writes the error on specified stream
+// GetPerfTrace returns the io.Writer to use for performance statistics
+func (s *Sqlcmd) GetPerfTrace() io.Writer {
+	if s.perf == nil {
+		return s.GetOutput()
+	}
+	return s.console(s.perf)
+}
+
+// SetPerfTrace sets the io.WriteCloser to use for performance statistics.
+// When it's nil the statistics are written to the output.
+func (s *Sqlcmd) SetPerfTrace(p io.WriteCloser) {
+	if s.perf != nil && s.perf != os.Stderr && s.perf != os.Stdout {
+		s.perf.Close()
+	}
+	s.perf = p
+}
+
+// WriteError writes the error on specified stream.
+// With ErrorsFormatJSON the error is written to stderr as a JSON object instead.
 func (s *Sqlcmd) WriteError(stream io.Writer, err error) {
+	if s.ErrorsFormat == ErrorsFormatJSON {
+		s.writeErrorEvent(errorEventKind, err)
+		return
+	}
 	if serr, ok := err.(SqlcmdError); ok {
-		if s.GetError() != os.Stdout {
+		if consoleFile(s.GetError()) != os.Stdout {
 			_, _ = s.GetError().Write([]byte(serr.Error() + SqlcmdEol))
 		} else {
-			_, _ = os.Stderr.Write([]byte(serr.Error() + SqlcmdEol))
+			_, _ = s.console(os.Stderr).Write([]byte(serr.Error() + SqlcmdEol))
 		}
 	} else {
-		_, _ = stream.Write([]byte(err.Error() + SqlcmdEol))
+		_, _ = s.console(stream).Write([]byte(err.Error() + SqlcmdEol))
 	}
 }
 
@@ -364,8 +430,10 @@ func (s *Sqlcmd) IncludeFile(path string, processAll bool) error {
 	}
 	defer f.Close()
 	b := s.batch.batchline
-	utf16bom := unicode.BOMOverride(unicode.UTF8.NewDecoder())
-	unicodeReader := transform.NewReader(f, utf16bom)
+	scriptFile, lineOffset := s.scriptFile, s.lineOffset
+	s.scriptFile, s.lineOffset = path, s.batch.linecount
+	defer func() { s.scriptFile, s.lineOffset = scriptFile, lineOffset }()
+	unicodeReader := transform.NewReader(f, s.inputDecoder())
 	scanner := bufio.NewReader(unicodeReader)
 	curLine := s.batch.read
 	echoFileLines := s.echoFileLines
@@ -454,7 +522,19 @@ func (s *Sqlcmd) getRunnableQuery(q string) string {
 // -102: Conversion error occurred when selecting return value
 func (s *Sqlcmd) runQuery(query string) (int, error) {
 	retcode := -101
-	s.Format.BeginBatch(query, s.vars, s.GetOutput(), s.GetError())
+	split, _ := s.out.(*splitOutput)
+	if split != nil {
+		split.nextBatch()
+	}
+	out, errOut := s.GetOutput(), s.GetError()
+	page := s.pagerOutput()
+	if page != nil {
+		if errOut == out {
+			errOut = page
+		}
+		out = page
+	}
+	s.Format.BeginBatch(query, s.vars, out, errOut)
 	ctx := context.Background()
 	timeout := s.vars.QueryTimeoutSeconds()
 	if timeout > 0 {
@@ -465,18 +545,22 @@ func (s *Sqlcmd) runQuery(query string) (int, error) {
 	retmsg := &sqlexp.ReturnMessage{}
 	rows, qe := s.db.QueryContext(ctx, query, retmsg)
 	if qe != nil {
-		s.Format.AddError(qe)
+		s.addError(qe)
 	}
 	var err error
 	var cols []*sql.ColumnType
+	var columns []Column
 	results := true
 	first := true
+	resultSets := 0
 	for qe == nil && results {
 		msg := retmsg.Message(ctx)
 		switch m := msg.(type) {
 		case sqlexp.MsgNotice:
-			if !s.PrintError(m.Message.String(), 10) {
-				s.Format.AddMessage(m.Message.String())
+			if s.perf != nil && isStatisticsMessage(m.Message) {
+				_, _ = s.GetPerfTrace().Write([]byte(m.Message.String() + SqlcmdEol))
+			} else if s.ErrorsFormat == ErrorsFormatJSON || !s.PrintError(m.Message.String(), 10) {
+				s.addMessage(m.Message)
 				switch e := m.Message.(type) {
 				case mssql.Error:
 					qe = s.handleError(&retcode, e)
@@ -485,23 +569,23 @@ func (s *Sqlcmd) runQuery(query string) (int, error) {
 		case sqlexp.MsgError:
 			switch e := m.Error.(type) {
 			case mssql.Error:
-				if !s.PrintError(e.Message, e.Class) {
-					s.Format.AddError(m.Error)
+				if s.ErrorsFormat == ErrorsFormatJSON || !s.PrintError(e.Message, e.Class) {
+					s.addError(m.Error)
 				}
 			}
 			qe = s.handleError(&retcode, m.Error)
 		case sqlexp.MsgRowsAffected:
 			if m.Count == 1 {
-				s.Format.AddMessage(localizer.Sprintf("(1 row affected)"))
+				s.addMessage(textMessage(localizer.Sprintf("(1 row affected)")))
 			} else {
-				s.Format.AddMessage(localizer.Sprintf("(%d rows affected)", m.Count))
+				s.addMessage(textMessage(localizer.Sprintf("(%d rows affected)", m.Count)))
 			}
 		case sqlexp.MsgNextResultSet:
 			results = rows.NextResultSet()
 			if err = rows.Err(); err != nil {
 				retcode = -100
 				qe = s.handleError(&retcode, err)
-				s.Format.AddError(err)
+				s.addError(err)
 			}
 			if results {
 				first = true
@@ -513,14 +597,41 @@ func (s *Sqlcmd) runQuery(query string) (int, error) {
 				if err != nil {
 					retcode = -100
 					qe = s.handleError(&retcode, err)
-					s.Format.AddError(err)
+					s.addError(err)
 				} else {
-					s.Format.BeginResultSet(cols)
+					resultSets++
+					if split != nil && resultSets > 1 {
+						split.nextResultSet()
+					}
+					if f, ok := s.Format.(RowFormatter); ok {
+						columns = newColumns(cols, s.vars)
+						f.BeginColumns(columns)
+					} else {
+						s.Format.BeginResultSet(cols)
+					}
 				}
 			}
 			inresult := rows.Next()
+			rowFormatter, scanRows := s.Format.(RowFormatter)
+			rowScanner, readsRows := s.Format.(RowScanner)
+			var reader *resultSetReader
+			if scanRows && inresult {
+				reader = s.newResultSetReader(cols, columns)
+			} else if inresult && !readsRows {
+				s.addError(localizer.Errorf("The rows of the result set were not written. The formatter implements neither AddValues nor AddRow"))
+			} else if inresult {
+				if err := s.blobOutUnsupported(); err != nil {
+					s.addError(err)
+				}
+			}
 			for inresult {
-				col1 := s.Format.AddRow(rows)
+				var col1 string
+				switch {
+				case scanRows:
+					col1 = s.addValues(rowFormatter, rows, reader)
+				case readsRows:
+					col1 = rowScanner.AddRow(rows)
+				}
 				inresult = rows.Next()
 				if !inresult {
 					if col1 == "" {
@@ -534,16 +645,69 @@ func (s *Sqlcmd) runQuery(query string) (int, error) {
 				if err = rows.Err(); err != nil {
 					retcode = -100
 					qe = s.handleError(&retcode, err)
-					s.Format.AddError(err)
+					s.addError(err)
 				}
 			}
 			s.Format.EndResultSet()
 		}
 	}
 	s.Format.EndBatch()
+	if page != nil {
+		if s.tee != nil {
+			s.tee.record(page.Bytes())
+		}
+		s.showPage(page)
+	}
 	return retcode, qe
 }
 
+// resultSetReader scans the rows of a result set for a RowFormatter. The buffer and the
+// column details are shared by the rows of the result set.
+type resultSetReader struct {
+	row *rowBuffer
+	// columns formats the values that sqlcmd uses itself
+	columns *sqlCmdFormatterType
+	// blobs writes large values to files while :BLOBOUT is on
+	blobs *blobColumns
+}
+
+func (s *Sqlcmd) newResultSetReader(cols []*sql.ColumnType, columns []Column) *resultSetReader {
+	r := &resultSetReader{row: newRowBuffer(len(cols)), columns: &sqlCmdFormatterType{vars: s.vars}}
+	r.columns.columnDetails, _ = columnDetailsOf(columns)
+	if s.blobOut != nil {
+		var err error
+		if r.blobs, err = s.blobOut.newBlobColumns(cols, r.row); err != nil {
+			s.addError(err)
+		}
+	}
+	return r
+}
+
+// blobOutUnsupported returns an error when :BLOBOUT is on but the formatter reads the rows itself,
+// so sqlcmd can't write the large values to files
+func (s *Sqlcmd) blobOutUnsupported() error {
+	if _, ok := s.Format.(RowFormatter); s.blobOut == nil || ok {
+		return nil
+	}
+	return localizer.Errorf("The large values were not written to files. :BLOBOUT requires a formatter that implements AddValues")
+}
+
+// addValues scans the current row and passes its values to the formatter.
+// It returns the value of the first column, which sets the exit code of :EXIT(query).
+func (s *Sqlcmd) addValues(f RowFormatter, rows *sql.Rows, r *resultSetReader) string {
+	if err := r.row.scan(rows); err != nil {
+		s.addError(err)
+		return ""
+	}
+	if r.blobs != nil {
+		if err := r.blobs.write(r.row.values, r.columns); err != nil {
+			s.addError(err)
+		}
+	}
+	f.AddValues(r.row.values)
+	return r.columns.firstValue(r.row.values)
+}
+
 // returns ErrExitRequested if the error is a SQL error and satisfies the connection's error handling configuration
 func (s *Sqlcmd) handleError(retcode *int, err error) error {
 	if err == nil {
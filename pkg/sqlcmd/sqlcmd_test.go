@@ -721,3 +721,14 @@ func TestSqlcmdPrefersSharedMemoryProtocol(t *testing.T) {
 	assert.EqualValuesf(t, "np", msdsn.ProtocolParsers[3].Protocol(), "np should be fourth protocol")
 
 }
+
+func TestRowFormatterReceivesScannedValues(t *testing.T) {
+	s, buf := setupSqlCmdWithMemoryOutput(t)
+	defer buf.Close()
+	f := &valuesFormatter{sqlCmdFormatterType: s.Format.(*sqlCmdFormatterType)}
+	s.Format = f
+	retcode, err := s.runQuery("select 1 as a, N'one' as b union all select 42, NULL")
+	assert.NoError(t, err, "runQuery")
+	assert.Equal(t, [][]interface{}{{int64(1), "one"}, {int64(42), nil}}, f.rows)
+	assert.Equal(t, 42, retcode, "the exit code is the first column of the last row")
+}
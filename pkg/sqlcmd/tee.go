@@ -0,0 +1,97 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"io"
+	"os"
+)
+
+// teeFile receives a copy of the output started by :TEE. Terminal escape sequences, such as the
+// colors of SQLCMDCOLORSCHEME, are removed from the copy.
+type teeFile struct {
+	file io.WriteCloser
+	// escape holds an escape sequence that continues in the next write
+	escape []byte
+}
+
+// record writes p to the file without its escape sequences
+func (t *teeFile) record(p []byte) {
+	text := make([]byte, 0, len(p))
+	for _, c := range p {
+		if len(t.escape) == 0 {
+			if c == 0x1b {
+				t.escape = append(t.escape, c)
+			} else {
+				text = append(text, c)
+			}
+			continue
+		}
+		t.escape = append(t.escape, c)
+		if escapeComplete(t.escape) {
+			t.escape = t.escape[:0]
+		}
+	}
+	_, _ = t.file.Write(text)
+}
+
+// escapeComplete returns true if e is a whole CSI sequence like ESC [ 1 ; 3 1 m, an OSC sequence
+// ended by BEL or ESC \, or a two character escape sequence
+func escapeComplete(e []byte) bool {
+	last := e[len(e)-1]
+	switch e[1] {
+	case '[':
+		return len(e) > 2 && last >= 0x40 && last <= 0x7e
+	case ']':
+		return last == 0x07 || (len(e) > 3 && e[len(e)-2] == 0x1b && last == '\\')
+	}
+	return true
+}
+
+func (t *teeFile) Close() error {
+	return t.file.Close()
+}
+
+// teeWriter writes to an output of sqlcmd and copies the text to the :TEE file
+type teeWriter struct {
+	out io.Writer
+	tee *teeFile
+}
+
+func (w teeWriter) Write(p []byte) (int, error) {
+	n, err := w.out.Write(p)
+	w.tee.record(p[:n])
+	return n, err
+}
+
+// Unwrap returns the output that receives the original text
+func (w teeWriter) Unwrap() io.Writer {
+	return w.out
+}
+
+// console returns w with a copy to the :TEE file when w writes to the console. Output
+// written to files isn't copied, so writers of file output keep their identity.
+func (s *Sqlcmd) console(w io.Writer) io.Writer {
+	if s.tee == nil || consoleFile(w) == nil {
+		return w
+	}
+	if _, ok := w.(teeWriter); ok {
+		return w
+	}
+	return teeWriter{out: w, tee: s.tee}
+}
+
+// consoleFile returns os.Stdout or os.Stderr if w writes to it, directly or through :TEE, otherwise nil
+func consoleFile(w io.Writer) *os.File {
+	if t, ok := w.(teeWriter); ok {
+		w = t.Unwrap()
+	}
+	switch w {
+	case io.Writer(os.Stdout):
+		return os.Stdout
+	case io.Writer(os.Stderr):
+		return os.Stderr
+	}
+	return nil
+}
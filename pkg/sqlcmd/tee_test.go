@@ -0,0 +1,127 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeeFileRemovesEscapeSequences(t *testing.T) {
+	b := &memoryBuffer{buf: new(bytes.Buffer)}
+	tee := &teeFile{file: b}
+	for _, chunk := range []string{"\x1b[38;5;", "33mselect\x1b[0m 1", "\x1b", "]8;;http://x\x07link\x1b]8;;\x1b\\ \x1b7done"} {
+		tee.record([]byte(chunk))
+	}
+	assert.Equal(t, "select 1link done", b.buf.String())
+}
+
+// redirectConsole replaces os.Stdout and os.Stderr with files for the duration of the test
+// and returns functions that read what was written to them
+func redirectConsole(t *testing.T) (stdout func() string, stderr func() string) {
+	t.Helper()
+	dir := t.TempDir()
+	origOut, origErr := os.Stdout, os.Stderr
+	t.Cleanup(func() { os.Stdout, os.Stderr = origOut, origErr })
+	read := func(f *os.File) func() string {
+		return func() string {
+			b, err := os.ReadFile(f.Name())
+			require.NoError(t, err)
+			return string(b)
+		}
+	}
+	var err error
+	os.Stdout, err = os.Create(filepath.Join(dir, "stdout"))
+	require.NoError(t, err)
+	os.Stderr, err = os.Create(filepath.Join(dir, "stderr"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.Stdout.Close(); _ = os.Stderr.Close() })
+	return read(os.Stdout), read(os.Stderr)
+}
+
+func readTeeFile(t *testing.T, file string) string {
+	t.Helper()
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestTeeCommand(t *testing.T) {
+	stdout, stderr := redirectConsole(t)
+	s := New(nil, "", InitializeVariables(false))
+	s.SetOutput(os.Stdout)
+	s.SetError(os.Stderr)
+	file := filepath.Join(t.TempDir(), "session.log")
+
+	assert.EqualError(t, teeCommand(s, []string{""}, 1), InvalidCommandError("TEE", 1).Error(), ":TEE without a file")
+	require.NoError(t, teeCommand(s, []string{file}, 1))
+	_, _ = s.GetOutput().Write([]byte("\x1b[1mname\x1b[0m" + SqlcmdEol))
+	_, _ = s.GetError().Write([]byte("Msg 208" + SqlcmdEol))
+	require.NoError(t, teeCommand(s, []string{"OFF"}, 1))
+	_, _ = s.GetOutput().Write([]byte("not copied" + SqlcmdEol))
+	require.NoError(t, teeCommand(s, []string{file + " append"}, 1))
+	_, _ = s.GetOutput().Write([]byte("more" + SqlcmdEol))
+	require.NoError(t, teeCommand(s, []string{" off "}, 1))
+
+	assert.Equal(t, "\x1b[1mname\x1b[0m"+SqlcmdEol+"not copied"+SqlcmdEol+"more"+SqlcmdEol, stdout(), "the output isn't changed")
+	assert.Equal(t, "Msg 208"+SqlcmdEol, stderr())
+	assert.Equal(t, "name"+SqlcmdEol+"Msg 208"+SqlcmdEol+"more"+SqlcmdEol, readTeeFile(t, file))
+	assert.Equal(t, io.Writer(os.Stdout), s.GetOutput(), ":TEE OFF restores the output")
+}
+
+func TestTeeKeepsErrorsOnStderr(t *testing.T) {
+	stdout, stderr := redirectConsole(t)
+	s := New(nil, "", InitializeVariables(false))
+	s.SetOutput(os.Stdout)
+	s.SetError(os.Stdout)
+	file := filepath.Join(t.TempDir(), "session.log")
+	require.NoError(t, teeCommand(s, []string{file}, 1))
+	s.WriteError(s.GetError(), InvalidCommandError("TEE", 3))
+	require.NoError(t, teeCommand(s, []string{"OFF"}, 1))
+
+	msg := InvalidCommandError("TEE", 3).Error() + SqlcmdEol
+	assert.Equal(t, "", stdout(), "sqlcmd errors go to stderr when errors are written to stdout")
+	assert.Equal(t, msg, stderr())
+	assert.Equal(t, msg, readTeeFile(t, file))
+}
+
+func TestTeeKeepsConsoleIdentity(t *testing.T) {
+	_, _ = redirectConsole(t)
+	s := New(nil, "", InitializeVariables(false))
+	s.SetOutput(os.Stdout)
+	require.NoError(t, teeCommand(s, []string{filepath.Join(t.TempDir(), "session.log")}, 1))
+	defer func() { _ = teeCommand(s, []string{"OFF"}, 1) }()
+
+	assert.Equal(t, os.Stdout, consoleFile(s.GetOutput()), "the pager still sees the console")
+	assert.Equal(t, os.Stdout, consoleFile(s.GetError()))
+	assert.Equal(t, s.GetOutput(), s.console(s.GetOutput()), "the copy isn't made twice")
+	assert.Error(t, requireOutputFile(s.GetOutput(), "parquet"), "binary formats still refuse the console")
+	_, err := numberedFileName(s.GetOutput(), 2)
+	assert.Error(t, err)
+}
+
+func TestTeeSkipsFileOutput(t *testing.T) {
+	dir := t.TempDir()
+	s := New(nil, "", InitializeVariables(false))
+	split := newSplitOutput(filepath.Join(dir, "out_{resultset}.parquet"), nil)
+	s.SetOutput(split)
+	defer s.SetOutput(nil)
+	file := filepath.Join(dir, "session.log")
+	require.NoError(t, teeCommand(s, []string{file}, 1))
+
+	assert.Equal(t, io.Writer(split), s.GetOutput(), "file output isn't wrapped")
+	assert.True(t, splitsResultSets(s.GetOutput()))
+	name, err := numberedFileName(s.GetOutput(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "out_1_2.parquet"), name)
+	_, _ = s.GetOutput().Write([]byte("PAR1"))
+	require.NoError(t, teeCommand(s, []string{"OFF"}, 1))
+	assert.Equal(t, "", readTeeFile(t, file), "output written to files isn't copied")
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/microsoft/go-mssqldb/msdsn"
+	"github.com/rivo/uniseg"
 )
 
 // splitServer extracts connection parameters from a server name input
@@ -59,6 +60,45 @@ func splitServer(serverName string) (string, instance string, port uint64, proto
 	return serverName, instance, port, protocol, err
 }
 
+// stringWidth returns the number of terminal columns s occupies. East Asian wide characters
+// and emoji take two columns and a grapheme cluster such as a flag counts as one character.
+func stringWidth(s string) int {
+	return uniseg.StringWidth(s)
+}
+
+// truncateWidth returns the leading grapheme clusters of s that fit in width terminal columns.
+// When a wide character doesn't fit, the result is padded with a space to fill width.
+func truncateWidth(s string, width int) string {
+	b := new(strings.Builder)
+	w := 0
+	state := -1
+	for s != "" {
+		var cluster string
+		var cw int
+		cluster, s, cw, state = uniseg.FirstGraphemeClusterInString(s, state)
+		if w+cw > width {
+			b.WriteString(strings.Repeat(" ", width-w))
+			break
+		}
+		b.WriteString(cluster)
+		w += cw
+	}
+	return b.String()
+}
+
+// skipWidth returns the part of s after its first width terminal columns.
+// A wide character that is split at the boundary is replaced by a space.
+func skipWidth(s string, width int) string {
+	w := 0
+	state := -1
+	for s != "" && w < width {
+		var cw int
+		_, s, cw, state = uniseg.FirstGraphemeClusterInString(s, state)
+		w += cw
+	}
+	return strings.Repeat(" ", max(w-width, 0)) + s
+}
+
 // padRight appends c instances of s to builder
 func padRight(builder *strings.Builder, c int64, s string) *strings.Builder {
 	var i int64
@@ -79,12 +119,3 @@ func padLeft(builder *strings.Builder, c int64, s string) *strings.Builder {
 	newBuilder.WriteString(builder.String())
 	return newBuilder
 }
-
-func contains(arr []string, s string) bool {
-	for _, a := range arr {
-		if a == s {
-			return true
-		}
-	}
-	return false
-}
@@ -6,6 +6,7 @@ package sqlcmd
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -36,6 +37,15 @@ const (
 	SQLCMDEDITOR            = "SQLCMDEDITOR"
 	SQLCMDUSEAAD            = "SQLCMDUSEAAD"
 	SQLCMDCOLORSCHEME       = "SQLCMDCOLORSCHEME"
+	SQLCMDCSVNULL           = "SQLCMDCSVNULL"
+	SQLCMDINSERTTABLE       = "SQLCMDINSERTTABLE"
+	SQLCMDPAGER             = "SQLCMDPAGER"
+	SQLCMDNULLDISPLAY       = "SQLCMDNULLDISPLAY"
+	SQLCMDDATETIMEFORMAT    = "SQLCMDDATETIMEFORMAT"
+	SQLCMDNUMBERFORMAT      = "SQLCMDNUMBERFORMAT"
+	SQLCMDCELLWRAP          = "SQLCMDCELLWRAP"
+	SQLCMDCELLWRAPLINES     = "SQLCMDCELLWRAPLINES"
+	SQLCMDSPATIALFORMAT     = "SQLCMDSPATIALFORMAT"
 )
 
 // builtinVariables are the predefined SQLCMD variables. Their values are printed first by :listvar
@@ -58,6 +68,15 @@ var builtinVariables = []string{
 	SQLCMDUSER,
 	SQLCMDWORKSTATION,
 	SQLCMDCOLORSCHEME,
+	SQLCMDCSVNULL,
+	SQLCMDINSERTTABLE,
+	SQLCMDPAGER,
+	SQLCMDNULLDISPLAY,
+	SQLCMDDATETIMEFORMAT,
+	SQLCMDNUMBERFORMAT,
+	SQLCMDCELLWRAP,
+	SQLCMDCELLWRAPLINES,
+	SQLCMDSPATIALFORMAT,
 }
 
 // readonlyVariables are variables that can't be changed via :setvar
@@ -181,12 +200,123 @@ func (v Variables) Format() string {
 		return "vertical"
 	case "ascii":
 		return "ascii"
+	case "json":
+		return "json"
+	case "csv":
+		return "csv"
+	case "md", "markdown":
+		return "markdown"
+	case "html":
+		return "html"
+	case "insert":
+		return "insert"
+	case "parquet":
+		return "parquet"
+	case "xlsx":
+		return "xlsx"
 	case "horiz", "horizontal":
 		return "horizontal"
 	}
+	if registeredFormatter(v[SQLCMDFORMAT]) != nil {
+		return strings.ToLower(v[SQLCMDFORMAT])
+	}
 	return "horizontal"
 }
 
+// CsvNullDisplay is the text the CSV formatter writes for NULL values.
+// SQLCMDCSVNULL takes precedence over a SQLCMDNULLDISPLAY value other than the default. Otherwise the field is empty.
+func (v Variables) CsvNullDisplay() string {
+	if v[SQLCMDCSVNULL] != "" {
+		return v[SQLCMDCSVNULL]
+	}
+	if n := v.NullDisplay(); n != defaultVariables[SQLCMDNULLDISPLAY] {
+		return n
+	}
+	return ""
+}
+
+// NullDisplay is the text the horizontal, vertical, ascii, markdown and html formatters print for NULL values.
+// The csv formatter uses CsvNullDisplay, and the json, insert, parquet and xlsx formatters ignore it.
+func (v Variables) NullDisplay() string {
+	if n, ok := v[SQLCMDNULLDISPLAY]; ok {
+		return n
+	}
+	return defaultVariables[SQLCMDNULLDISPLAY]
+}
+
+// DateTimeFormat is how the horizontal, vertical, ascii, markdown, html and csv formatters print date and time values.
+// "odbc" matches ODBC sqlcmd, "iso8601" uses the ISO 8601 format with a T separator,
+// and "epoch" prints the number of seconds since 1970-01-01 UTC.
+func (v Variables) DateTimeFormat() string {
+	switch strings.ToLower(v[SQLCMDDATETIMEFORMAT]) {
+	case "iso", "iso8601":
+		return "iso8601"
+	case "epoch", "unix":
+		return "epoch"
+	}
+	return "odbc"
+}
+
+// NumberFormat is the pattern the horizontal, vertical, ascii, markdown, html and csv formatters use to print
+// numeric values, such as "#,##0.00".
+// When empty, numbers are printed as returned by the server.
+func (v Variables) NumberFormat() string {
+	return v[SQLCMDNUMBERFORMAT]
+}
+
+// InsertTableName is the target table of the statements written by the insert formatter.
+// When empty each result set gets a generated name.
+func (v Variables) InsertTableName() string {
+	return v[SQLCMDINSERTTABLE]
+}
+
+// Pager is the command that shows interactive query results one screen at a time.
+// An empty value or "off" disables paging and "builtin" selects the pager built into sqlcmd.
+// "on" uses the command in the PAGER environment variable, or the built-in pager when it isn't set.
+func (v Variables) Pager() string {
+	switch strings.ToLower(v[SQLCMDPAGER]) {
+	case "", "off":
+		return ""
+	case "on":
+		if p := os.Getenv("PAGER"); p != "" {
+			return p
+		}
+		return builtinPager
+	case builtinPager:
+		return builtinPager
+	}
+	return v[SQLCMDPAGER]
+}
+
+// CellWrapWidth is the width at which the ascii formatter wraps long cell values onto more lines.
+// Values of 0 or less, or values that aren't numbers, disable wrapping.
+func (v Variables) CellWrapWidth() int {
+	return positiveValue(v[SQLCMDCELLWRAP])
+}
+
+// CellWrapLines is the maximum number of lines of a wrapped cell. Longer values end with an ellipsis.
+// 0 means no limit.
+func (v Variables) CellWrapLines() int {
+	return positiveValue(v[SQLCMDCELLWRAPLINES])
+}
+
+// SpatialFormat is how geometry and geography values are printed: "wkt" for Well-Known Text,
+// "ewkt" for Well-Known Text prefixed with the SRID, or "hex" for the serialized bytes
+func (v Variables) SpatialFormat() string {
+	switch strings.ToLower(v[SQLCMDSPATIALFORMAT]) {
+	case "hex":
+		return "hex"
+	case "ewkt":
+		return "ewkt"
+	}
+	return "wkt"
+}
+
+// PacketSize is the network packet size requested for the connection
+func (v Variables) PacketSize() int {
+	return positiveValue(v[SQLCMDPACKETSIZE])
+}
+
 // StartupScriptFile is the path to the file that contains the startup script
 func (v Variables) StartupScriptFile() string {
 	return v[SQLCMDINI]
@@ -216,6 +346,15 @@ func mustValue(val string) int64 {
 	panic(err)
 }
 
+// positiveValue returns the number in val, or 0 if val isn't a positive number
+func positiveValue(val string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
 // defaultVariables defines variables that cannot be removed from the map, only reset
 // to their default values.
 var defaultVariables = Variables{
@@ -228,6 +367,11 @@ var defaultVariables = Variables{
 	SQLCMDMAXFIXEDTYPEWIDTH: "0",
 	SQLCMDMAXVARTYPEWIDTH:   "256",
 	SQLCMDSTATTIMEOUT:       "0",
+	SQLCMDNULLDISPLAY:       "NULL",
+	SQLCMDDATETIMEFORMAT:    "odbc",
+	SQLCMDCELLWRAP:          "0",
+	SQLCMDCELLWRAPLINES:     "0",
+	SQLCMDSPATIALFORMAT:     "wkt",
 }
 
 // InitializeVariables initializes variables with default values.
@@ -251,6 +395,15 @@ func InitializeVariables(fromEnvironment bool) *Variables {
 		SQLCMDUSEAAD:            "",
 		SQLCMDCOLORSCHEME:       "",
 		SQLCMDFORMAT:            "",
+		SQLCMDCSVNULL:           "",
+		SQLCMDINSERTTABLE:       "",
+		SQLCMDPAGER:             "",
+		SQLCMDNULLDISPLAY:       defaultVariables[SQLCMDNULLDISPLAY],
+		SQLCMDDATETIMEFORMAT:    defaultVariables[SQLCMDDATETIMEFORMAT],
+		SQLCMDNUMBERFORMAT:      "",
+		SQLCMDCELLWRAP:          defaultVariables[SQLCMDCELLWRAP],
+		SQLCMDCELLWRAPLINES:     defaultVariables[SQLCMDCELLWRAPLINES],
+		SQLCMDSPATIALFORMAT:     defaultVariables[SQLCMDSPATIALFORMAT],
 	}
 	hostname, _ := os.Hostname()
 	variables.Set(SQLCMDWORKSTATION, hostname)
@@ -117,3 +117,15 @@ func TestValidIdentifier(t *testing.T) {
 		}
 	}
 }
+
+func TestCsvNullDisplayPrecedence(t *testing.T) {
+	vars := InitializeVariables(false)
+	assert.Equal(t, "", vars.CsvNullDisplay(), "CSV NULL fields are empty by default")
+	vars.Set(SQLCMDNULLDISPLAY, "(null)")
+	assert.Equal(t, "(null)", vars.CsvNullDisplay(), "SQLCMDNULLDISPLAY applies when set")
+	vars.Set(SQLCMDCSVNULL, `\N`)
+	assert.Equal(t, `\N`, vars.CsvNullDisplay(), "SQLCMDCSVNULL takes precedence")
+	assert.Equal(t, "odbc", vars.DateTimeFormat())
+	vars.Set(SQLCMDDATETIMEFORMAT, "bogus")
+	assert.Equal(t, "odbc", vars.DateTimeFormat(), "unknown formats use the default")
+}
@@ -0,0 +1,141 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"strings"
+)
+
+// xmlIndentString is the indentation added for each level of nested elements
+const xmlIndentString = "  "
+
+// xmlIndenter reformats a stream of XML text, such as the rows of a FOR XML query, with one
+// element per line. It only holds the incomplete markup at the end of a chunk and the current
+// line, so documents of any size are reformatted as they arrive.
+type xmlIndenter struct {
+	// pending is the start of markup or text that continues in the next chunk
+	pending string
+	// line is the output line that the next token may extend
+	line  strings.Builder
+	depth int
+	// open is true while the line ends with a start tag or its text, so the end tag can follow on the same line
+	open bool
+}
+
+// write adds a chunk of the stream and returns the lines that are complete
+func (x *xmlIndenter) write(s string) string {
+	data := x.pending + s
+	x.pending = ""
+	out := new(strings.Builder)
+	for i := 0; i < len(data); {
+		if data[i] != '<' {
+			end := strings.IndexByte(data[i:], '<')
+			if end < 0 {
+				x.pending = data[i:]
+				break
+			}
+			x.text(out, data[i:i+end])
+			i += end
+			continue
+		}
+		end := markupEnd(data[i:])
+		if end < 0 {
+			x.pending = data[i:]
+			break
+		}
+		x.markup(out, data[i:i+end])
+		i += end
+	}
+	return out.String()
+}
+
+// flush returns the rest of the output and resets the indenter for the next document
+func (x *xmlIndenter) flush() string {
+	out := new(strings.Builder)
+	if x.pending != "" {
+		x.text(out, x.pending)
+	}
+	out.WriteString(x.line.String())
+	x.pending = ""
+	x.line.Reset()
+	x.depth = 0
+	x.open = false
+	return out.String()
+}
+
+// markupEnd returns the length of the tag, comment, CDATA section or processing instruction
+// at the start of s, or -1 if it isn't complete yet
+func markupEnd(s string) int {
+	for _, delim := range [][2]string{{"<!--", "-->"}, {"<![CDATA[", "]]>"}, {"<?", "?>"}} {
+		if strings.HasPrefix(s, delim[0]) {
+			if end := strings.Index(s[len(delim[0]):], delim[1]); end >= 0 {
+				return len(delim[0]) + end + len(delim[1])
+			}
+			return -1
+		}
+		// wait for enough of the chunk to tell which kind of markup it is
+		if len(s) < len(delim[0]) && strings.HasPrefix(delim[0], s) {
+			return -1
+		}
+	}
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == '>':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// newLine starts a line at the current depth, moving the previous line to out
+func (x *xmlIndenter) newLine(out *strings.Builder) {
+	if x.line.Len() > 0 {
+		out.WriteString(x.line.String())
+		out.WriteString(SqlcmdEol)
+		x.line.Reset()
+	}
+	x.line.WriteString(strings.Repeat(xmlIndentString, x.depth))
+}
+
+func (x *xmlIndenter) markup(out *strings.Builder, m string) {
+	switch {
+	case strings.HasPrefix(m, "<![CDATA["):
+		x.text(out, m)
+	case strings.HasPrefix(m, "</"):
+		x.depth = max(x.depth-1, 0)
+		if !x.open {
+			x.newLine(out)
+		}
+		x.line.WriteString(m)
+		x.open = false
+	case strings.HasPrefix(m, "<!") || strings.HasPrefix(m, "<?") || strings.HasSuffix(m, "/>"):
+		x.newLine(out)
+		x.line.WriteString(m)
+		x.open = false
+	default:
+		x.newLine(out)
+		x.line.WriteString(m)
+		x.depth++
+		x.open = true
+	}
+}
+
+// text adds character data. Whitespace between elements is replaced by the indentation.
+func (x *xmlIndenter) text(out *strings.Builder, t string) {
+	if strings.TrimSpace(t) == "" {
+		return
+	}
+	if !x.open {
+		x.newLine(out)
+		t = strings.TrimSpace(t)
+	}
+	x.line.WriteString(t)
+}
@@ -0,0 +1,88 @@
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT license.
+
+package sqlcmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func indentXml(chunks ...string) string {
+	x := new(xmlIndenter)
+	s := new(strings.Builder)
+	for _, c := range chunks {
+		s.WriteString(x.write(c))
+	}
+	s.WriteString(x.flush())
+	return strings.ReplaceAll(s.String(), SqlcmdEol, "\n")
+}
+
+func TestXmlIndenter(t *testing.T) {
+	expected := `<?xml version="1.0"?>
+<root>
+  <!-- a > b -->
+  <item id="1" note="a>b">first</item>
+  <item id="2">
+    <empty/>
+    <empty></empty>
+    <![CDATA[<not markup>]]>
+  </item>
+</root>`
+	doc := `<?xml version="1.0"?><root><!-- a > b --><item id="1" note="a>b">first</item>` +
+		"\r\n  <item id=\"2\"><empty/><empty></empty><![CDATA[<not markup>]]></item></root>"
+	assert.Equal(t, expected, indentXml(doc))
+	for size := 1; size < 12; size++ {
+		var chunks []string
+		for i := 0; i < len(doc); i += size {
+			chunks = append(chunks, doc[i:min(i+size, len(doc))])
+		}
+		assert.Equal(t, expected, indentXml(chunks...), "chunks of %d bytes", size)
+	}
+	assert.Equal(t, "<a>text\n  <b/>\n  tail\n</a>", indentXml("<a>text<b/>tail</a>"), "mixed content")
+	assert.Equal(t, "plain text", indentXml("plain ", "text"))
+	assert.Equal(t, "<a>1</a>\nx", indentXml("<a>1</a>x"), "text at the end of the stream follows the last complete line")
+}
+
+func TestXmlIndenterWritesCompleteLines(t *testing.T) {
+	x := new(xmlIndenter)
+	assert.Equal(t, "", x.write("<root><ite"))
+	assert.Equal(t, "<root>"+SqlcmdEol, x.write(`m a="1">value</item><item`), "the line of an element that may continue is held back")
+	assert.Equal(t, `  <item a="1">value</item>`+SqlcmdEol+"  <item/>"+SqlcmdEol, x.write("/></root>"))
+	assert.Equal(t, "</root>"+SqlcmdEol, x.write("<next>"))
+	assert.Equal(t, "<next>"+SqlcmdEol, x.write("<x/>"))
+	assert.Equal(t, "  <x/>", x.flush())
+	assert.Equal(t, "<new/>", indentXml("<new/>"), "flush resets the depth")
+}
+
+func TestXmlCommandIndent(t *testing.T) {
+	vars := InitializeVariables(false)
+	s := New(nil, "", vars)
+	s.Format = NewSQLCmdDefaultFormatter(vars, false, ControlIgnore)
+	assert.NoError(t, xmlCommand(s, []string{"on indent"}, 1))
+	assert.True(t, s.Format.IsXmlMode())
+	assert.True(t, isXmlIndent(s.Format))
+	assert.NoError(t, xmlCommand(s, []string{"ON"}, 1))
+	assert.True(t, s.Format.IsXmlMode())
+	assert.False(t, isXmlIndent(s.Format))
+	assert.EqualError(t, xmlCommand(s, []string{"ON PRETTY"}, 1), InvalidCommandError("XML", 1).Error())
+	assert.NoError(t, xmlCommand(s, []string{"OFF"}, 1))
+	assert.False(t, s.Format.IsXmlMode())
+	assert.False(t, isXmlIndent(s.Format))
+}
+
+// plainFormatter is a Formatter that doesn't support indenting XML mode output
+type plainFormatter struct {
+	Formatter
+}
+
+func TestXmlCommandIndentWithPlainFormatter(t *testing.T) {
+	vars := InitializeVariables(false)
+	s := New(nil, "", vars)
+	s.Format = plainFormatter{NewSQLCmdDefaultFormatter(vars, false, ControlIgnore)}
+	assert.NoError(t, xmlCommand(s, []string{"ON INDENT"}, 1))
+	assert.True(t, s.Format.IsXmlMode())
+	assert.False(t, isXmlIndent(s.Format), "formatters without XmlIndent print XML mode output unchanged")
+}